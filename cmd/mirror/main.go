@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,14 +10,20 @@ import (
 	"sync"
 	"time"
 
-	"github.com/robfig/cron/v3"
-
+	"lemwood_mirror/internal/blobs"
 	"lemwood_mirror/internal/browser"
 	"lemwood_mirror/internal/config"
 	"lemwood_mirror/internal/db"
 	"lemwood_mirror/internal/downloader"
+	"lemwood_mirror/internal/federation"
 	gh "lemwood_mirror/internal/github"
+	"lemwood_mirror/internal/quota"
+	"lemwood_mirror/internal/replica"
+	"lemwood_mirror/internal/retry"
+	"lemwood_mirror/internal/scans"
+	"lemwood_mirror/internal/scheduler"
 	"lemwood_mirror/internal/server"
+	"lemwood_mirror/internal/version"
 )
 
 type LauncherState struct {
@@ -24,6 +31,8 @@ type LauncherState struct {
 	RepoURL  string
 	Version  string
 	LastScan time.Time
+	Scanning bool
+	Rescan   *server.ScanOptions // 扫描进行中又收到了立即扫描的请求，结束后按其选项再扫描一次
 }
 
 func main() {
@@ -39,103 +48,517 @@ func main() {
 	if err := db.InitDB(base); err != nil {
 		log.Fatalf("初始化数据库失败: %v", err)
 	}
+	if err := scans.MarkInterrupted(); err != nil {
+		log.Printf("标记中断的扫描失败: %v", err)
+	}
 	s := server.NewState(base)
+	s.SiteURL = cfg.PublicBaseURL()
+	// 版本排序方案需要在加载磁盘索引之前设置，以便正确选出最新版本
+	for _, l := range cfg.Launchers {
+		cmp, err := version.New(version.Spec{Scheme: l.VersionScheme, Pattern: l.VersionRegex, Order: l.VersionRegexOrder})
+		if err != nil {
+			log.Fatalf("%s: 版本排序方案配置无效: %v", l.Name, err)
+		}
+		s.SetVersionScheme(l.Name, cmp)
+	}
+	// 启动器定义可以通过管理接口在运行期修改，扫描时总是读取最新的列表
+	registry := config.NewRegistry(projectRoot, cfg.Launchers)
+	s.Launchers = registry
+	s.AdminToken = cfg.AdminToken
+	s.WebhookSecret = cfg.WebhookSecret
+	if cfg.StaleAfterHours > 0 {
+		s.StaleAfter = time.Duration(cfg.StaleAfterHours) * time.Hour
+	}
+	// 管理员的固定与撤回设置会影响最新版本的选择，需要先于磁盘索引加载
+	if err := s.LoadOverrides(); err != nil {
+		log.Printf("加载版本干预失败: %v", err)
+	}
 	if err := s.InitFromDisk(); err != nil {
 		log.Printf("初始化索引失败: %v", err)
 	}
+	// 下载、GitHub API 与仓库解析共用同一重试策略
+	retryPolicy := retry.Policy{
+		MaxAttempts:   cfg.Retry.MaxAttempts,
+		BaseDelay:     time.Duration(cfg.Retry.BaseDelaySeconds * float64(time.Second)),
+		MaxDelay:      time.Duration(cfg.Retry.MaxDelaySeconds * float64(time.Second)),
+		Jitter:        retry.Default().Jitter,
+		MaxRetryAfter: time.Duration(cfg.Retry.MaxRetryAfterMinutes) * time.Minute,
+	}
+	// 显式配置为 0 时关闭抖动，未配置时使用默认值
+	if cfg.Retry.Jitter != nil {
+		retryPolicy.Jitter = *cfg.Retry.Jitter
+	}
 	ghc := gh.NewClient(cfg.GitHubToken)
+	ghc.Retry = retryPolicy
+	resolver := browser.NewResolver(browser.ResolverOptions{
+		ProxyURL:     cfg.ProxyURL,
+		UserAgent:    cfg.UserAgent,
+		Timeout:      time.Duration(cfg.ResolverTimeoutSeconds) * time.Second,
+		CacheTTL:     time.Duration(cfg.ResolverCacheMinutes) * time.Minute,
+		ChangePolicy: cfg.RepoChangePolicy,
+		Retry:        retryPolicy,
+	})
+	s.Resolver = resolver
+
+	// 上游下载策略：未配置 download_mirrors 时沿用 asset_proxy_url 与 xget 设置
+	mirrorSpecs := downloader.LegacyMirrorSpecs(cfg.AssetProxyURL, cfg.XgetEnabled, cfg.XgetDomain)
+	if len(cfg.DownloadMirrors) > 0 {
+		mirrorSpecs = nil
+		for _, m := range cfg.DownloadMirrors {
+			mirrorSpecs = append(mirrorSpecs, downloader.MirrorSpec{Name: m.Name, Type: m.Type, URL: m.URL})
+		}
+	}
+	mirrors := downloader.NewMirrorSet(mirrorSpecs)
+	mirrors.StartHealthChecks(time.Duration(cfg.MirrorCheckMinutes)*time.Minute, cfg.MirrorProbeURL, cfg.ProxyURL)
+	s.Mirrors = mirrors
+
+	// 全局带宽限制与大资源下载时间窗口
+	limiter := downloader.NewRateLimiter(int64(cfg.BandwidthLimitKBps) * 1024)
+	var schedule *downloader.Schedule
+	if len(cfg.DownloadWindows) > 0 {
+		schedule = &downloader.Schedule{
+			MinSize:      int64(cfg.WindowMinSizeMB) << 20,
+			BypassLatest: cfg.WindowBypassLatest,
+		}
+		for _, w := range cfg.DownloadWindows {
+			win, err := downloader.ParseWindow(w.Start, w.End)
+			if err != nil {
+				log.Fatalf("无效的下载时间窗口 %s-%s: %v", w.Start, w.End, err)
+			}
+			schedule.Windows = append(schedule.Windows, win)
+		}
+	}
+	// 所有启动器共享同一个下载队列，并发上限对整个进程生效
+	perHost := cfg.PerHostDownloads
+	if perHost <= 0 {
+		perHost = cfg.ConcurrentDownloads
+	}
+	downer := downloader.NewDownloader(cfg.DownloadTimeoutMinutes, cfg.ConcurrentDownloads, perHost)
+	downer.Mirrors = mirrors
+	downer.Limiter = limiter
+	downer.Schedule = schedule
+	downer.ProxyURL = cfg.ProxyURL
+	downer.Retry = retryPolicy
+	// 相同内容的资源在磁盘上只保存一份
+	store := blobs.New(base)
+	downer.Blobs = store
+	s.Blobs = store
+	// 下载前检查可用空间与配额
+	guard := quota.New(base)
+	guard.Total = int64(cfg.DiskQuotaMB) << 20
+	if cfg.MinFreeSpaceMB > 0 {
+		guard.MinFree = int64(cfg.MinFreeSpaceMB) << 20
+	}
+	guard.LauncherQuota = func(name string) int64 {
+		lcfg, _ := registry.Get(name)
+		return int64(lcfg.QuotaMB) << 20
+	}
+	// 副本的版本集合跟随主节点，提前删除的版本会在下次同步时重新下载
+	if cfg.QuotaPolicy == config.QuotaPrune && !cfg.Replica.Enabled() {
+		// 新版本发布后本来就会按保留策略删除最旧的版本，空间不足时提前删除，为新版本腾出位置
+		guard.Reclaim = func(name string) {
+			lcfg, _ := registry.Get(name)
+			keep := lcfg.Keep(cfg.KeepVersions)
+			if keep <= 0 {
+				return
+			}
+			if keep > 1 {
+				keep--
+			}
+			removed, err := s.Prune(name, keep)
+			if err != nil {
+				log.Printf("%s: 为新版本腾出空间失败: %v", name, err)
+			} else if len(removed) > 0 {
+				log.Printf("%s: 空间不足，提前清理了 %d 个旧版本", name, len(removed))
+			}
+		}
+	}
+	downer.Quota = guard
+	downer.Trackers = cfg.TorrentTrackers
+	s.Quota = guard
+	// 去重会替换已发布的文件，必须在下载队列与首次扫描开始发布之前完成
+	if files, saved, err := downloader.DedupePublished(store, base); err != nil {
+		log.Printf("去重已发布的资源失败: %v", err)
+	} else if files > 0 {
+		log.Printf("已将 %d 个已发布的资源纳入去重存储，节省 %d 字节", files, saved)
+	}
+	// 补充生成种子与 Metalink 会改写 index.json，与去重一样在切换最新版本与发布新版本之前完成
+	if n, err := downer.GenerateSidecars(base, func(launcher, version, infoPath string) {
+		s.RefreshInfo(infoPath)
+	}); err != nil {
+		log.Printf("生成已发布资源的种子与 Metalink 失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已为 %d 个已发布的版本补充生成种子与 Metalink", n)
+	}
+	// 观察期结束的版本由后台定期发布
+	s.StartPromoter(time.Minute)
+	if err := downer.Start(context.Background()); err != nil {
+		log.Fatalf("启动下载队列失败: %v", err)
+	}
+	s.Downloader = downer
+
+	// 联邦中的其他节点
+	if len(cfg.Federation.Peers) > 0 {
+		var peers []federation.Peer
+		for _, p := range cfg.Federation.Peers {
+			peers = append(peers, federation.Peer{Name: p.Name, URL: p.URL, Region: p.Region, Countries: p.Countries, Weight: p.Weight})
+		}
+		fed := federation.New(peers, cfg.Federation.LocalCountries)
+		fed.Local = s.Catalog
+		fed.Start(time.Duration(cfg.Federation.CheckMinutes) * time.Minute)
+		s.Federation = fed
+	}
+
+	// 副本从主节点同步版本，不访问 GitHub
+	var syncer *replica.Syncer
+	if cfg.Replica.Enabled() {
+		syncer = replica.New(cfg.Replica.PrimaryURL, base, s, downer)
+		syncer.ServerAddress = cfg.ServerAddress
+		syncer.ServerPort = cfg.ServerPort
+		syncer.DownloadURLBase = cfg.DownloadUrlBase
+		syncer.Timeout = time.Duration(cfg.DownloadTimeoutMinutes) * time.Minute
+		s.Replica = syncer
+		log.Printf("以副本模式运行，主节点: %s", cfg.Replica.PrimaryURL)
+	}
+
+	var deferMu sync.Mutex
+	var deferTimer *time.Timer
+	var deferAt time.Time
 
 	var mu sync.Mutex
-	var scanMu sync.Mutex
 	launchers := make(map[string]*LauncherState)
-	for _, l := range cfg.Launchers {
-		launchers[l.Name] = &LauncherState{Name: l.Name}
+
+	// githubRelease 获取仓库的最新 release（tag 非空时为指定版本）
+	githubRelease := func(ctx context.Context, repoURL, tag string) (*downloader.Release, error) {
+		owner, repo, err := gh.ParseOwnerRepo(repoURL)
+		if err != nil {
+			return nil, fmt.Errorf("解析 owner/repo 失败: %w", err)
+		}
+		if tag != "" {
+			rel, _, err := ghc.ReleaseByTag(ctx, owner, repo, tag)
+			if err != nil {
+				return nil, fmt.Errorf("获取 release %s 失败: %w", tag, err)
+			}
+			return downloader.FromGitHub(rel.RepositoryRelease, rel.Digests), nil
+		}
+		rel, _, err := ghc.LatestRelease(ctx, owner, repo)
+		if err != nil {
+			return nil, fmt.Errorf("获取最新 release 失败: %w", err)
+		}
+		return downloader.FromGitHub(rel.RepositoryRelease, rel.Digests), nil
+	}
+	// fetchRelease 获取启动器上游的最新版本（tag 非空时为指定版本），返回版本信息与来源地址
+	fetchRelease := func(ctx context.Context, lcfg config.LauncherConfig, tag string) (*downloader.Release, string, error) {
+		if lcfg.IsWebpage() {
+			if tag != "" {
+				return nil, "", errors.New("下载页来源不支持指定版本")
+			}
+			rel, err := resolver.WebRelease(ctx, lcfg.SourceURL, browser.WebSpec{
+				LinkSelector:   lcfg.LinkSelector,
+				AssetPattern:   lcfg.AssetPattern,
+				VersionPattern: lcfg.VersionPattern,
+				Compare:        s.Comparator(lcfg.Name),
+			})
+			if err != nil {
+				return nil, "", fmt.Errorf("抓取下载页失败: %w", err)
+			}
+			log.Printf("%s: 使用下载页 %s", lcfg.Name, lcfg.SourceURL)
+			return rel, lcfg.SourceURL, nil
+		}
+		repoURL, err := resolver.Resolve(ctx, lcfg.Name, lcfg.SourceURL, lcfg.Selectors()...)
+		if err != nil {
+			return nil, "", fmt.Errorf("解析仓库地址失败: %w", err)
+		}
+		log.Printf("%s: 使用仓库 %s", lcfg.Name, repoURL)
+		rel, err := githubRelease(ctx, repoURL, tag)
+		return rel, repoURL, err
+	}
+
+	var scan func()
+	// deferredScan 在推迟的资源所在窗口开启时安排一次补充扫描，只保留最早的一个
+	deferredScan := func(at time.Time) {
+		deferMu.Lock()
+		defer deferMu.Unlock()
+		if deferTimer != nil && !deferAt.After(at) {
+			return
+		}
+		if deferTimer != nil {
+			deferTimer.Stop()
+		}
+		deferAt = at
+		deferTimer = time.AfterFunc(time.Until(at), func() {
+			deferMu.Lock()
+			deferTimer = nil
+			deferMu.Unlock()
+			log.Printf("下载时间窗口已开启，开始补充扫描")
+			scan()
+		})
 	}
 
-	scan := func() {
-		if !scanMu.TryLock() {
-			log.Printf("扫描已在进行中，跳过此次执行")
+	// scanLauncher 检查单个启动器的上游版本并镜像新版本，同一启动器同时只有一次扫描。
+	// opts 可以强制重新下载或指定要镜像的版本；指定的版本只加入索引，不切换最新版本，也不触发旧版本清理。
+	// followUp 为 true 时（webhook、管理操作等立即扫描的请求），如果扫描已在进行中，
+	// 则在其结束后再扫描一次，避免进行中的扫描错过刚发布的版本。
+	var scanLauncher func(lcfg config.LauncherConfig, budget *retry.Budget, opts server.ScanOptions, followUp bool)
+	scanLauncher = func(lcfg config.LauncherConfig, budget *retry.Budget, opts server.ScanOptions, followUp bool) {
+		mu.Lock()
+		ls := launchers[lcfg.Name]
+		if ls == nil {
+			ls = &LauncherState{Name: lcfg.Name}
+			launchers[lcfg.Name] = ls
+		}
+		if ls.Scanning {
+			if followUp {
+				if ls.Rescan != nil && ls.Rescan.Tag == opts.Tag {
+					opts.Force = opts.Force || ls.Rescan.Force
+				}
+				ls.Rescan = &opts
+			}
+			mu.Unlock()
+			log.Printf("%s: 扫描已在进行中，跳过此次执行", lcfg.Name)
+			return
+		}
+		ls.Scanning = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			ls.Scanning = false
+			rescan := ls.Rescan
+			ls.Rescan = nil
+			mu.Unlock()
+			if rescan != nil {
+				if next, ok := registry.Get(lcfg.Name); ok && !next.Disabled {
+					log.Printf("%s: 扫描期间收到新的扫描请求，再次扫描", lcfg.Name)
+					go scanLauncher(next, retry.NewBudget(cfg.Retry.ScanBudget), *rescan, false)
+				}
+			}
+		}()
+
+		// 每次扫描的结果都记录到数据库，并检查启动器是否长时间未能同步
+		rec := scans.Begin(lcfg.Name)
+		outcome, upstream := scans.OutcomeFailed, ""
+		var fetched int64
+		var scanErr error
+		defer func() {
+			rec.Finish(outcome, upstream, fetched, scanErr)
+			s.CheckStale(lcfg.Name)
+		}()
+
+		timeout := time.Duration(cfg.DownloadTimeoutMinutes) * time.Minute
+		ctx, cancel := context.WithTimeout(retry.WithBudget(context.Background(), budget), timeout)
+		defer cancel()
+		rel, repoURL, err := fetchRelease(ctx, lcfg, opts.Tag)
+		if err != nil {
+			log.Printf("%s: %v", lcfg.Name, err)
+			scanErr = err
+			return
+		}
+		version := rel.Version()
+		upstream = version
+		
+		// 检查是否已经是最新版本，避免重复下载；上游重新上传了资源时仍需重新镜像
+		reuploaded := downloader.AssetsChanged(base, lcfg.Name, rel)
+		mu.Lock()
+		if ls.Version == version && ls.RepoURL == repoURL && !reuploaded && !opts.Force && opts.Tag == "" {
+			mu.Unlock()
+			log.Printf("%s: 版本 %s 已是最新，跳过下载", lcfg.Name, version)
+			outcome = scans.OutcomeUpToDate
 			return
 		}
-		defer scanMu.Unlock()
+		mu.Unlock()
+		if yanked, reason := s.Yanked(lcfg.Name, version); yanked {
+			log.Printf("%s: 版本 %s 已被管理员撤回（%s），跳过下载", lcfg.Name, version, reason)
+			outcome, scanErr = scans.OutcomeSkipped, fmt.Errorf("版本已被撤回: %s", reason)
+			return
+		}
+		
+		result, err := downer.DownloadLatest(ctx, lcfg.Name, base, rel, cfg.ServerAddress, cfg.ServerPort, cfg.DownloadUrlBase, opts.Tag == "", opts.Force)
+		for _, a := range result.Assets {
+			fetched += a.Bytes
+			if !a.Skipped && !a.Deferred && a.Error == "" {
+				log.Printf("%s: %s 通过 %s 下载，%d 字节，用时 %s", lcfg.Name, a.Name, a.Strategy, a.Bytes, a.Duration.Round(time.Second))
+			}
+		}
+		if errors.Is(err, downloader.ErrDeferred) {
+			at := schedule.NextOpen(time.Now())
+			log.Printf("%s: %v，将于 %s 继续", lcfg.Name, err, at.Format("2006-01-02 15:04"))
+			deferredScan(at)
+			outcome, scanErr = scans.OutcomeDeferred, err
+			return
+		}
+		if errors.Is(err, quota.ErrInsufficientSpace) {
+			log.Printf("%s: 拒绝下载版本 %s: %v", lcfg.Name, version, err)
+			outcome, scanErr = scans.OutcomeNoSpace, err
+			return
+		}
+		if err != nil {
+			log.Printf("%s: 下载失败: %v", lcfg.Name, err)
+			scanErr = fmt.Errorf("下载失败: %w", err)
+			return
+		}
+		
+		// 需要观察期或人工批准的版本在加入索引之前登记为待发布，加入索引时不会被选为最新版本。
+		// 启动器被固定时同样登记，取消固定后这些版本仍要经过观察期或批准
+		pinned := s.Pinned(lcfg.Name)
+		held := false
+		if opts.Tag == "" {
+			if held, err = s.Stage(lcfg.Name, version, promotion(lcfg)); err != nil {
+				log.Printf("%s: 登记待发布版本失败: %v", lcfg.Name, err)
+				scanErr = fmt.Errorf("登记待发布版本失败: %w", err)
+				return
+			}
+		}
+		// 新版本完整发布后再切换 latest 标记，失败时旧版本保持为最新，下次扫描重试
+		s.UpdateIndex(lcfg.Name, version, result.InfoPath)
+		if opts.Tag != "" {
+			log.Printf("%s: 已镜像指定版本 %s", lcfg.Name, version)
+			outcome = scans.OutcomeUpdated
+			return
+		}
+		if pinned != "" {
+			log.Printf("%s: 最新版本已被管理员固定为 %s，不切换到 %s", lcfg.Name, pinned, version)
+		} else if !held {
+			if err := s.SwitchLatest(lcfg.Name, version, "扫描发现新版本"); err != nil {
+				log.Printf("%s: 切换最新版本失败: %v", lcfg.Name, err)
+				scanErr = fmt.Errorf("切换最新版本失败: %w", err)
+				return
+			}
+		}
+		if removed, err := s.Prune(lcfg.Name, lcfg.Keep(cfg.KeepVersions)); err != nil {
+			log.Printf("%s: 清理旧版本失败: %v", lcfg.Name, err)
+		} else if len(removed) > 0 {
+			log.Printf("%s: 已清理 %d 个旧版本", lcfg.Name, len(removed))
+		}
+		mu.Lock()
+		ls.RepoURL = repoURL
+		ls.Version = version
+		ls.LastScan = time.Now()
+		mu.Unlock()
+		log.Printf("%s: 已更新至 %s", lcfg.Name, version)
+		outcome = scans.OutcomeUpdated
+	}
+
+	// scan 立即扫描全部启用的启动器，用于启动时、手动触发与下载时间窗口开启时
+	scan = func() {
 		log.Printf("扫描开始")
 		wg := sync.WaitGroup{}
-		for _, lcfg := range cfg.Launchers {
+		for _, lcfg := range registry.List() {
+			if lcfg.Disabled {
+				continue
+			}
 			lcfg := lcfg
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
-				timeout := time.Duration(cfg.DownloadTimeoutMinutes) * time.Minute
-				ctx, cancel := context.WithTimeout(context.Background(), timeout)
-				defer cancel()
-				repoURL, err := browser.ResolveRepoURL(lcfg.SourceURL, lcfg.RepoSelector)
-				if err != nil {
-					log.Printf("%s: 解析仓库地址失败: %v", lcfg.Name, err)
-					return
-				}
-				log.Printf("%s: 使用仓库 %s", lcfg.Name, repoURL)
-				owner, repo, err := gh.ParseOwnerRepo(repoURL)
-				if err != nil {
-					log.Printf("%s: 解析 owner/repo 失败: %v", lcfg.Name, err)
-					return
-				}
-				rel, resp, err := ghc.LatestRelease(ctx, owner, repo)
-				if err != nil {
-					log.Printf("%s: 获取最新 release 失败: %v", lcfg.Name, err)
-					gh.BackoffIfRateLimited(resp)
-					return
-				}
-				version := rel.GetTagName()
-				if version == "" {
-					version = rel.GetName()
-				}
-				
-				// 检查是否已经是最新版本，避免重复下载
-				mu.Lock()
-				ls := launchers[lcfg.Name]
-				if ls.Version == version {
-					mu.Unlock()
-					log.Printf("%s: 版本 %s 已是最新，跳过下载", lcfg.Name, version)
-					return
-				}
-				mu.Unlock()
-				
-				// 清除该启动器所有旧版本的 latest 标记
-				if err := s.ClearLatestFlags(lcfg.Name); err != nil {
-					log.Printf("%s: 清除旧版本 latest 标记失败: %v", lcfg.Name, err)
-				}
-				
-				downer := downloader.NewDownloader(cfg.DownloadTimeoutMinutes, cfg.ConcurrentDownloads)
-				infoPath, err := downer.DownloadLatest(ctx, lcfg.Name, base, cfg.ProxyURL, cfg.AssetProxyURL, cfg.XgetEnabled, cfg.XgetDomain, rel, cfg.ServerAddress, cfg.ServerPort, cfg.DownloadUrlBase, true)
-				if err != nil {
-					log.Printf("%s: 下载失败: %v", lcfg.Name, err)
-					return
-				}
-				
-				s.UpdateIndex(lcfg.Name, version, infoPath)
-				mu.Lock()
-				ls.RepoURL = repoURL
-				ls.Version = version
-				ls.LastScan = time.Now()
-				mu.Unlock()
-				log.Printf("%s: 已更新至 %s", lcfg.Name, version)
+				// 每个启动器的检查使用各自的重试预算，一个启动器的上游故障不会耗尽其他启动器的重试次数
+				scanLauncher(lcfg, retry.NewBudget(cfg.Retry.ScanBudget), server.ScanOptions{}, false)
 			}()
 		}
 		wg.Wait()
 		log.Printf("扫描完成")
 	}
 
-	// 初始扫描
-	go scan()
+	// 管理接口添加或修改启动器后、收到 GitHub webhook 时立即扫描该启动器
+	s.ScanLauncher = func(name string, opts server.ScanOptions) {
+		lcfg, ok := registry.Get(name)
+		if !ok || lcfg.Disabled {
+			return
+		}
+		scanLauncher(lcfg, retry.NewBudget(cfg.Retry.ScanBudget), opts, true)
+	}
+	// 试运行只获取上游元数据并比较本地文件，不下载也不修改磁盘
+	s.PlanScan = func(ctx context.Context, name string, opts server.ScanOptions) server.ScanPlan {
+		plan := server.ScanPlan{Launcher: name, Action: server.PlanMirror}
+		lcfg, ok := registry.Get(name)
+		if !ok {
+			plan.Action, plan.Reason = server.PlanError, "启动器不存在"
+			return plan
+		}
+		// 仓库解析使用 Peek：不保存解析结果、不告警，也不按变更策略切换信任的仓库
+		var rel *downloader.Release
+		var repoURL string
+		var err error
+		if lcfg.IsWebpage() {
+			rel, repoURL, err = fetchRelease(ctx, lcfg, opts.Tag)
+		} else {
+			repoURL, plan.RepoChange, err = resolver.Peek(ctx, lcfg.Name, lcfg.SourceURL, lcfg.Selectors()...)
+			if err != nil {
+				err = fmt.Errorf("解析仓库地址失败: %w", err)
+			} else {
+				rel, err = githubRelease(ctx, repoURL, opts.Tag)
+			}
+		}
+		if err != nil {
+			plan.Action, plan.Reason = server.PlanError, err.Error()
+			return plan
+		}
+		version := rel.Version()
+		plan.Plan = downloader.PlanDownload(base, name, rel, opts.Force)
+		// 与 scanLauncher 的判断顺序保持一致
+		reuploaded := downloader.AssetsChanged(base, name, rel)
+		mu.Lock()
+		ls := launchers[name]
+		upToDate := ls != nil && ls.Version == version && ls.RepoURL == repoURL && !reuploaded && !opts.Force && opts.Tag == ""
+		mu.Unlock()
+		if upToDate {
+			plan.Action = server.PlanUpToDate
+			plan.Plan = &downloader.Plan{Version: version, Fetch: []downloader.PlannedAsset{}, Reuse: []string{}, Replace: []string{}}
+		} else if yanked, reason := s.Yanked(name, version); yanked {
+			plan.Action, plan.Reason = server.PlanSkip, "版本已被撤回: "+reason
+		} else if err := guard.Check(name, plan.FetchBytes); err != nil {
+			// 试运行不会提前清理旧版本，quota_policy 为 prune 时实际扫描仍可能成功
+			plan.Action, plan.Reason = server.PlanNoSpace, err.Error()
+		}
+		if c := plan.RepoChange; c != nil {
+			plan.Action = server.PlanRepoChanged
+			plan.Reason = fmt.Sprintf("源页面指向的仓库从 %s 变为 %s，扫描时将告警并按策略 %s 使用 %s", c.Known, c.Found, c.Policy, repoURL)
+		}
+		return plan
+	}
+	// 定时检查不需要补充扫描
+	scheduled := func(name string) {
+		lcfg, ok := registry.Get(name)
+		if !ok || lcfg.Disabled {
+			return
+		}
+		scanLauncher(lcfg, retry.NewBudget(cfg.Retry.ScanBudget), server.ScanOptions{}, false)
+	}
 
-	// 定时任务
-	c := cron.New()
-	_, err = c.AddFunc(cfg.CheckCron, scan)
-	if err != nil {
-		log.Fatalf("无效的 cron 表达式 %q: %v", cfg.CheckCron, err)
+	if syncer != nil {
+		// 手动扫描、webhook 与管理操作触发的扫描都改为立即与主节点同步
+		scan = syncer.Sync
+		s.ScanLauncher = func(name string, opts server.ScanOptions) {
+			syncer.Sync()
+		}
+		s.PlanScan = func(ctx context.Context, name string, opts server.ScanOptions) server.ScanPlan {
+			return server.ScanPlan{Launcher: name, Action: server.PlanError, Reason: "副本模式从主节点同步，不检查上游"}
+		}
+		syncer.Start(time.Duration(cfg.Replica.PollMinutes) * time.Minute)
+	} else {
+		// 初始扫描
+		go scan()
+
+		// 每个启动器按自己的计划独立检查，未单独配置的使用全局 check_cron
+		sched := scheduler.New(scheduled, s.ReleaseTimes)
+		for _, l := range registry.List() {
+			if l.Disabled {
+				continue
+			}
+			if err := sched.Set(l.Name, scheduleSpec(cfg, l)); err != nil {
+				log.Fatalf("%s: 检查计划无效: %v", l.Name, err)
+			}
+		}
+		defer sched.Stop()
+		registry.OnChange = func(l config.LauncherConfig, ok bool) {
+			if !ok || l.Disabled {
+				sched.Remove(l.Name)
+				return
+			}
+			if err := sched.Set(l.Name, scheduleSpec(cfg, l)); err != nil {
+				log.Printf("%s: 检查计划无效: %v", l.Name, err)
+			}
+		}
 	}
-	c.Start()
-	defer c.Stop()
 
 	// 带有手动扫描端点的 HTTP 服务器
 	addr := fmt.Sprintf(":%d", cfg.ServerPort)
@@ -144,3 +567,36 @@ func main() {
 		log.Fatalf("http 服务器出错: %v", err)
 	}
 }
+
+// promotion 将启动器的发布方式配置转换为 server.Promotion
+func promotion(l config.LauncherConfig) server.Promotion {
+	switch l.Promotion {
+	case config.PromotionManual:
+		return server.Promotion{Manual: true}
+	case config.PromotionSoak:
+		return server.Promotion{Soak: time.Duration(l.SoakHours) * time.Hour}
+	}
+	return server.Promotion{}
+}
+
+// scheduleSpec 根据全局与启动器配置生成检查计划
+func scheduleSpec(cfg *config.Config, l config.LauncherConfig) scheduler.Spec {
+	spec := scheduler.Spec{
+		Cron:     l.CheckCron,
+		Interval: time.Duration(l.CheckIntervalMinutes) * time.Minute,
+		Jitter:   time.Duration(cfg.CheckJitterSeconds) * time.Second,
+	}
+	if l.CheckJitterSeconds > 0 {
+		spec.Jitter = time.Duration(l.CheckJitterSeconds) * time.Second
+	}
+	if l.AdaptivePolling {
+		spec.Adaptive = &scheduler.Adaptive{
+			Min: time.Duration(cfg.AdaptiveMinMinutes) * time.Minute,
+			Max: time.Duration(cfg.AdaptiveMaxMinutes) * time.Minute,
+		}
+	}
+	if spec.Cron == "" && spec.Interval <= 0 && spec.Adaptive == nil {
+		spec.Cron = cfg.CheckCron
+	}
+	return spec
+}
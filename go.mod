@@ -3,17 +3,18 @@ module lemwood_mirror
 go 1.24.0
 
 require (
+	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/antchfx/htmlquery v1.2.3
 	github.com/gocolly/colly/v2 v2.1.0
 	github.com/google/go-github/v50 v50.1.0
 	github.com/robfig/cron/v3 v3.0.1
 	golang.org/x/oauth2 v0.22.0
+	golang.org/x/sys v0.36.0
 	modernc.org/sqlite v1.40.1
 )
 
 require (
-	github.com/PuerkitoBio/goquery v1.5.1 // indirect
 	github.com/andybalholm/cascadia v1.2.0 // indirect
-	github.com/antchfx/htmlquery v1.2.3 // indirect
 	github.com/antchfx/xmlquery v1.2.4 // indirect
 	github.com/antchfx/xpath v1.1.8 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -31,7 +32,6 @@ require (
 	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5 // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
 	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110 // indirect
-	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.3.3 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.24.0 // indirect
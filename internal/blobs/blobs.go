@@ -0,0 +1,232 @@
+package blobs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"lemwood_mirror/internal/db"
+)
+
+// DirName 是存储根目录下保存 blob 的隐藏目录名
+const DirName = ".blobs"
+
+// Ref 标识引用 blob 的已发布资源
+type Ref struct {
+	Launcher string
+	Version  string
+	Name     string
+}
+
+// Stats 汇总去重的效果
+type Stats struct {
+	Blobs           int64 `json:"blobs"`
+	References      int64 `json:"references"`
+	StoredBytes     int64 `json:"stored_bytes"`     // 去重后实际占用的空间
+	ReferencedBytes int64 `json:"referenced_bytes"` // 不去重时需要的空间
+	SavedBytes      int64 `json:"saved_bytes"`
+}
+
+// Store 是按 SHA-256 寻址的资源存储：<base>/.blobs/<前两位>/<sha256>。
+// 已发布的资源文件是 blob 的硬链接（不支持时使用 reflink），相同内容在磁盘上只保存一份；
+// 引用记录在 blob_refs 表中，没有引用的 blob 才会被删除。
+type Store struct {
+	dir string
+	mu  sync.Mutex // 串行化链接与回收，避免回收刚被引用的 blob
+}
+
+// New 创建 base 下的 blob 存储
+func New(base string) *Store {
+	return &Store{dir: filepath.Join(base, DirName)}
+}
+
+// Path 返回 blob 的路径
+func (s *Store) Path(sum string) string {
+	if len(sum) < 2 {
+		return filepath.Join(s.dir, sum)
+	}
+	return filepath.Join(s.dir, sum[:2], sum)
+}
+
+// Link 将 path 处 SHA-256 为 sum 的文件纳入存储，并记录 ref 引用它。
+// 内容相同的 blob 已存在时，path 被替换为指向它的链接，返回节省的字节数；否则 path 本身成为新的 blob。
+// ref 原先引用的 blob 不再被任何资源引用时会被删除。
+func (s *Store) Link(path, sum string, ref Ref) (int64, error) {
+	if db.DB == nil {
+		return 0, errors.New("数据库未初始化")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blob := s.Path(sum)
+	var saved int64
+	bi, err := os.Stat(blob)
+	switch {
+	case err == nil && bi.Size() == fi.Size():
+		if !os.SameFile(bi, fi) {
+			if err := replaceWith(blob, path); err != nil {
+				return 0, fmt.Errorf("链接到 blob %s 失败: %w", sum, err)
+			}
+			saved = fi.Size()
+		}
+	default:
+		// blob 不存在，或大小不符（已损坏），用 path 重新建立
+		if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+			return 0, err
+		}
+		os.Remove(blob)
+		if err := linkOrClone(path, blob); err != nil {
+			return 0, fmt.Errorf("创建 blob %s 失败: %w", sum, err)
+		}
+	}
+
+	var old sql.NullString
+	err = db.DB.QueryRow(`SELECT sha256 FROM blob_refs WHERE launcher = ? AND version = ? AND name = ?`,
+		ref.Launcher, ref.Version, ref.Name).Scan(&old)
+	if err != nil && err != sql.ErrNoRows {
+		return saved, fmt.Errorf("读取 blob 引用失败: %w", err)
+	}
+	if _, err := db.DB.Exec(`INSERT INTO blobs (sha256, size) VALUES (?, ?) ON CONFLICT(sha256) DO UPDATE SET size = excluded.size`,
+		sum, fi.Size()); err != nil {
+		return saved, fmt.Errorf("记录 blob 失败: %w", err)
+	}
+	if _, err := db.DB.Exec(`INSERT INTO blob_refs (launcher, version, name, sha256) VALUES (?, ?, ?, ?)
+        ON CONFLICT(launcher, version, name) DO UPDATE SET sha256 = excluded.sha256`,
+		ref.Launcher, ref.Version, ref.Name, sum); err != nil {
+		return saved, fmt.Errorf("记录 blob 引用失败: %w", err)
+	}
+	if old.Valid && old.String != sum {
+		if _, err := s.collect(old.String); err != nil {
+			return saved, err
+		}
+	}
+	return saved, nil
+}
+
+// Linked 判断 ref 已记录引用 sum，且 path 与 blob 是同一个文件，用于跳过已去重的资源
+func (s *Store) Linked(path, sum string, ref Ref) bool {
+	if db.DB == nil {
+		return false
+	}
+	var current string
+	if err := db.DB.QueryRow(`SELECT sha256 FROM blob_refs WHERE launcher = ? AND version = ? AND name = ?`,
+		ref.Launcher, ref.Version, ref.Name).Scan(&current); err != nil || (sum != "" && current != sum) {
+		return false
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(s.Path(current))
+	return err == nil && os.SameFile(fi, bi)
+}
+
+// Release 删除版本的全部引用，并删除不再被引用的 blob，返回删除的 blob 大小之和。
+// 应在版本目录删除之后调用。
+func (s *Store) Release(launcher, version string) (int64, error) {
+	if db.DB == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows, err := db.DB.Query(`SELECT DISTINCT sha256 FROM blob_refs WHERE launcher = ? AND version = ?`, launcher, version)
+	if err != nil {
+		return 0, fmt.Errorf("读取 blob 引用失败: %w", err)
+	}
+	var sums []string
+	for rows.Next() {
+		var sum string
+		if err := rows.Scan(&sum); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		sums = append(sums, sum)
+	}
+	rows.Close()
+	if _, err := db.DB.Exec(`DELETE FROM blob_refs WHERE launcher = ? AND version = ?`, launcher, version); err != nil {
+		return 0, fmt.Errorf("删除 blob 引用失败: %w", err)
+	}
+	var freed int64
+	for _, sum := range sums {
+		n, err := s.collect(sum)
+		if err != nil {
+			return freed, err
+		}
+		freed += n
+	}
+	return freed, nil
+}
+
+// collect 在 blob 没有引用时删除它，返回删除的大小，调用方需持有 mu
+func (s *Store) collect(sum string) (int64, error) {
+	var refs int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM blob_refs WHERE sha256 = ?`, sum).Scan(&refs); err != nil {
+		return 0, fmt.Errorf("统计 blob 引用失败: %w", err)
+	}
+	if refs > 0 {
+		return 0, nil
+	}
+	var size int64
+	db.DB.QueryRow(`SELECT size FROM blobs WHERE sha256 = ?`, sum).Scan(&size)
+	if err := os.Remove(s.Path(sum)); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("删除 blob %s 失败: %w", sum, err)
+	}
+	if _, err := db.DB.Exec(`DELETE FROM blobs WHERE sha256 = ?`, sum); err != nil {
+		return 0, fmt.Errorf("删除 blob 记录失败: %w", err)
+	}
+	return size, nil
+}
+
+// Stats 返回 blob 数量、引用数量与节省的空间
+func (s *Store) Stats() (Stats, error) {
+	var st Stats
+	if db.DB == nil {
+		return st, errors.New("数据库未初始化")
+	}
+	err := db.DB.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size), 0) FROM blobs
+        WHERE sha256 IN (SELECT sha256 FROM blob_refs)`).Scan(&st.Blobs, &st.StoredBytes)
+	if err != nil {
+		return st, err
+	}
+	err = db.DB.QueryRow(`SELECT COUNT(*), COALESCE(SUM(b.size), 0) FROM blob_refs r JOIN blobs b ON b.sha256 = r.sha256`).
+		Scan(&st.References, &st.ReferencedBytes)
+	if err != nil {
+		return st, err
+	}
+	st.SavedBytes = st.ReferencedBytes - st.StoredBytes
+	return st, nil
+}
+
+// replaceWith 将 path 原子地替换为 blob 的链接
+func replaceWith(blob, path string) error {
+	// 临时文件以 "." 开头，替换过程中不会被列出或下载
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".blob-tmp")
+	os.Remove(tmp)
+	if err := linkOrClone(blob, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// linkOrClone 优先创建硬链接，失败时（如超过链接数上限）尝试 reflink
+func linkOrClone(src, dst string) error {
+	err := os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+	if cerr := clone(src, dst); cerr != nil {
+		return fmt.Errorf("%v；reflink 也失败: %v", err, cerr)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package blobs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// clone 使用 FICLONE 创建与 src 共享数据块的副本（btrfs、XFS 等支持 reflink 的文件系统）
+func clone(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package blobs
+
+import "errors"
+
+// clone 在不支持 reflink 的系统上总是失败
+func clone(src, dst string) error {
+	return errors.New("不支持 reflink")
+}
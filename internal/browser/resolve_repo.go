@@ -1,97 +1,136 @@
 package browser
 
 import (
-    "errors"
-    "fmt"
-    "net/url"
-    "regexp"
-    "strings"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
-    "github.com/gocolly/colly/v2"
+	"github.com/gocolly/colly/v2"
+
+	gh "lemwood_mirror/internal/github"
+	"lemwood_mirror/internal/retry"
 )
 
 // ResolveRepoURL 访问给定的源 URL 并尝试查找 GitHub 仓库链接。
 // 如果源已经是 github.com URL，则直接返回。
-// repoSelector: "regex:<pattern>" 将使用正则表达式匹配锚点 href。
-// 否则视为 CSS 选择器，使用第一个匹配元素的 href。
-func ResolveRepoURL(source string, repoSelector string) (string, error) {
-    if source == "" {
-        return "", errors.New("源 url 为空")
-    }
-    u, err := url.Parse(source)
-    if err != nil {
-        return "", fmt.Errorf("无效的源 url: %w", err)
-    }
+// repoSelectors 按顺序尝试，第一个成功提取到仓库地址的选择器生效，支持的写法见 extractRepoURL。
+// 未提供选择器时，使用第一个形如 https://github.com/<owner>/<repo> 的锚点 href。
+// 返回值已规范化为 https://github.com/<owner>/<repo>。
+// 该函数不使用缓存也不做变更检测，适合一次性校验；扫描流程请使用 Resolver。
+func ResolveRepoURL(source string, repoSelectors ...string) (string, error) {
+	return ResolveRepoURLContext(context.Background(), source, repoSelectors...)
+}
+
+// ResolveRepoURLContext 与 ResolveRepoURL 相同，ctx 取消时停止重试
+func ResolveRepoURLContext(ctx context.Context, source string, repoSelectors ...string) (string, error) {
+	return crawlRepoURL(ctx, source, repoSelectors, defaultOptions())
+}
+
+// crawlRepoURL 按 opts 中的代理、超时、User-Agent 与重试策略获取源页面，并依次尝试各个选择器
+func crawlRepoURL(ctx context.Context, source string, repoSelectors []string, opts ResolverOptions) (string, error) {
+	if source == "" {
+		return "", errors.New("源 url 为空")
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("无效的源 url: %w", err)
+	}
 
-    // 如果源看起来像直接的 GitHub 仓库 URL (https://github.com/owner/repo)，则按原样返回
-    if strings.Contains(u.Host, "github.com") {
-        parts := strings.Split(strings.Trim(u.Path, "/"), "/")
-        if len(parts) >= 2 && parts[0] != "" && parts[1] != "" {
-            // Direct repo URL
-            return "https://github.com/" + parts[0] + "/" + parts[1], nil
-        }
-        // 否则，它是 GitHub 页面（例如搜索/结果）。我们将爬取下面的锚点。
-    }
+	// 如果源看起来像直接的 GitHub 仓库 URL (https://github.com/owner/repo)，则按原样返回
+	if strings.Contains(u.Host, "github.com") {
+		if repoURL, err := gh.NormalizeRepoURL(source); err == nil {
+			// Direct repo URL
+			return repoURL, nil
+		}
+		// 否则，它是 GitHub 页面（例如搜索/结果）。我们将爬取下面的锚点。
+	}
 
-    c := colly.NewCollector(
-        colly.MaxDepth(1),
-        colly.AllowedDomains(u.Host),
-    )
-    var found string
-    var re *regexp.Regexp
-    cssSelector := "a"
-    if repoSelector != "" {
-        if strings.HasPrefix(repoSelector, "regex:") {
-            pattern := strings.TrimPrefix(repoSelector, "regex:")
-            compiled, err := regexp.Compile(pattern)
-            if err != nil {
-                return "", fmt.Errorf("repo_selector 中的正则表达式无效: %w", err)
-            }
-            re = compiled
-            cssSelector = "a"
-        } else {
-            cssSelector = repoSelector
-        }
-    }
+	page, err := fetchPageRetry(ctx, source, opts)
+	if err != nil {
+		return "", err
+	}
 
-    // 默认严格匹配：https://github.com/<owner>/<repo> 或 /<owner>/<repo>
-    defaultAbsRe := regexp.MustCompile(`^https://github\.com/[^/]+/[^/#?]+$`)
-    defaultRelRe := regexp.MustCompile(`^/[^/]+/[^/#?]+$`)
+	if len(repoSelectors) == 0 {
+		repoSelectors = []string{""}
+	}
+	var errs []string
+	for _, sel := range repoSelectors {
+		found, err := extractRepoURL(page, sel)
+		if err == nil {
+			return gh.NormalizeRepoURL(found)
+		}
+		if sel == "" {
+			sel = "(默认)"
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", sel, err))
+	}
+	return "", fmt.Errorf("未从源页面找到 github 仓库 url（%s）", strings.Join(errs, "; "))
+}
+
+// page 是一次抓取得到的页面内容
+type page struct {
+	URL         *url.URL
+	ContentType string
+	Body        []byte
+}
+
+// fetchPageRetry 按 opts.Retry 重试 fetchPage
+func fetchPageRetry(ctx context.Context, source string, opts ResolverOptions) (*page, error) {
+	var p *page
+	err := opts.Retry.Do(ctx, func(int) error {
+		var err error
+		p, err = fetchPage(source, opts)
+		return err
+	})
+	return p, err
+}
 
-    c.OnHTML(cssSelector, func(e *colly.HTMLElement) {
-        if found != "" {
-            return
-        }
-        href := strings.TrimSpace(e.Attr("href"))
-        if href == "" {
-            return
-        }
-        // 如果提供了自定义正则表达式
-        if re != nil {
-            if re.MatchString(href) {
-                if strings.HasPrefix(href, "/") {
-                    found = "https://github.com" + href
-                } else {
-                    found = href
-                }
-            }
-            return
-        }
-        // 否则对 GitHub 仓库 URL 应用默认匹配
-        if defaultAbsRe.MatchString(href) {
-            found = href
-            return
-        }
-        if defaultRelRe.MatchString(href) {
-            found = "https://github.com" + href
-            return
-        }
-    })
-    if err := c.Visit(source); err != nil {
-        return "", fmt.Errorf("访问源失败: %w", err)
-    }
-    if found == "" {
-        return "", errors.New("未从源页面找到 github 仓库 url")
-    }
-    return found, nil
+// fetchPage 使用 colly 获取单个页面，非 2xx 响应返回 *retry.HTTPError
+func fetchPage(source string, opts ResolverOptions) (*page, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("无效的源 url: %w", err)
+	}
+	c := colly.NewCollector(
+		colly.MaxDepth(1),
+		colly.AllowedDomains(u.Hostname()),
+		colly.UserAgent(opts.UserAgent),
+	)
+	c.SetRequestTimeout(opts.Timeout)
+	if opts.ProxyURL != "" {
+		if err := c.SetProxy(opts.ProxyURL); err != nil {
+			return nil, fmt.Errorf("设置代理失败: %w", err)
+		}
+	}
+	var p *page
+	c.OnResponse(func(r *colly.Response) {
+		p = &page{
+			URL:         r.Request.URL,
+			ContentType: r.Headers.Get("Content-Type"),
+			Body:        r.Body,
+		}
+	})
+	var httpErr *retry.HTTPError
+	c.OnError(func(r *colly.Response, err error) {
+		if r == nil || r.StatusCode == 0 {
+			return
+		}
+		httpErr = &retry.HTTPError{StatusCode: r.StatusCode, Err: fmt.Errorf("访问源失败，状态码: %d", r.StatusCode)}
+		if r.Headers != nil {
+			httpErr.RetryAfter = retry.ParseRetryAfter(r.Headers.Get("Retry-After"), time.Now())
+		}
+	})
+	if err := c.Visit(source); err != nil {
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		return nil, fmt.Errorf("访问源失败: %w", err)
+	}
+	if p == nil {
+		return nil, errors.New("访问源失败: 没有响应")
+	}
+	return p, nil
 }
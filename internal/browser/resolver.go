@@ -0,0 +1,261 @@
+package browser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"lemwood_mirror/internal/db"
+	"lemwood_mirror/internal/events"
+	gh "lemwood_mirror/internal/github"
+	"lemwood_mirror/internal/retry"
+)
+
+// 仓库变更策略
+const (
+	// ChangePolicyBlock 检测到源页面指向新仓库时告警，并继续使用之前信任的仓库
+	ChangePolicyBlock = "block"
+	// ChangePolicyFollow 检测到变更时告警，并切换到新仓库
+	ChangePolicyFollow = "follow"
+)
+
+// ResolverOptions 控制仓库解析的网络行为与缓存
+type ResolverOptions struct {
+	ProxyURL     string
+	UserAgent    string
+	Timeout      time.Duration
+	CacheTTL     time.Duration
+	ChangePolicy string
+	Retry        retry.Policy
+}
+
+func defaultOptions() ResolverOptions {
+	return ResolverOptions{
+		UserAgent:    "lemwood-mirror/1.0 (+https://mirror.lemwood.icu/)",
+		Timeout:      30 * time.Second,
+		CacheTTL:     6 * time.Hour,
+		ChangePolicy: ChangePolicyBlock,
+		Retry:        retry.Default(),
+	}
+}
+
+type cacheEntry struct {
+	repoURL    string
+	resolvedAt time.Time
+}
+
+// Resolver 在 ResolveRepoURL 的基础上提供结果缓存、重命名/转移跟踪以及仓库变更检测。
+// 每个启动器最近一次信任的仓库地址保存在数据库中，重启后仍能发现源页面被篡改。
+type Resolver struct {
+	opts   ResolverOptions
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewResolver 创建解析器，未设置的选项使用默认值
+func NewResolver(opts ResolverOptions) *Resolver {
+	def := defaultOptions()
+	if opts.UserAgent == "" {
+		opts.UserAgent = def.UserAgent
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = def.Timeout
+	}
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = def.CacheTTL
+	}
+	if opts.ChangePolicy != ChangePolicyFollow {
+		opts.ChangePolicy = ChangePolicyBlock
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+	if opts.ProxyURL != "" {
+		if proxy, err := url.Parse(opts.ProxyURL); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+		} else {
+			log.Printf("解析代理URL失败，仓库解析将不使用代理: %v", err)
+		}
+	}
+	return &Resolver{
+		opts:   opts,
+		client: client,
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+// Resolve 解析启动器的仓库地址，repoSelectors 为按顺序尝试的选择器。结果在 CacheTTL 内直接复用。
+func (r *Resolver) Resolve(ctx context.Context, launcher, source string, repoSelectors ...string) (string, error) {
+	key := source + "\x00" + strings.Join(repoSelectors, "\x00")
+	r.mu.Lock()
+	if e, ok := r.cache[key]; ok && time.Since(e.resolvedAt) < r.opts.CacheTTL {
+		r.mu.Unlock()
+		return e.repoURL, nil
+	}
+	r.mu.Unlock()
+
+	found, err := crawlRepoURL(ctx, source, repoSelectors, r.opts)
+	if err != nil {
+		return "", err
+	}
+	canonical := r.followRenames(found)
+	repoURL, err := r.checkChange(launcher, canonical)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.cache[key] = cacheEntry{repoURL: repoURL, resolvedAt: time.Now()}
+	r.mu.Unlock()
+	return repoURL, nil
+}
+
+// RepoChange 描述源页面指向的仓库与已信任仓库不一致
+type RepoChange struct {
+	Known  string `json:"known"`  // 之前信任的仓库
+	Found  string `json:"found"`  // 源页面当前指向的仓库
+	Policy string `json:"policy"` // 实际扫描时采用的变更策略
+}
+
+// Lookup 按解析器的代理、User-Agent、超时与重试策略抓取源页面，返回跟随重命名后的仓库地址。
+// 不使用缓存，也不与已信任的仓库比较，用于校验管理员提交的启动器来源。
+func (r *Resolver) Lookup(ctx context.Context, source string, repoSelectors ...string) (string, error) {
+	found, err := crawlRepoURL(ctx, source, repoSelectors, r.opts)
+	if err != nil {
+		return "", err
+	}
+	return r.followRenames(found), nil
+}
+
+// Peek 与 Resolve 一样解析仓库地址，但不使用缓存、不保存结果也不发出告警，用于试运行。
+// 返回实际扫描将使用的仓库；源页面指向的仓库与已信任的不一致（且不是重命名）时 change 非空。
+func (r *Resolver) Peek(ctx context.Context, launcher, source string, repoSelectors ...string) (repoURL string, change *RepoChange, err error) {
+	canonical, err := r.Lookup(ctx, source, repoSelectors...)
+	if err != nil {
+		return "", nil, err
+	}
+	known, err := r.KnownRepo(launcher)
+	if err != nil {
+		return "", nil, err
+	}
+	if launcher == "" || known == "" || known == canonical || r.followRenames(known) == canonical {
+		return canonical, nil, nil
+	}
+	change = &RepoChange{Known: known, Found: canonical, Policy: r.opts.ChangePolicy}
+	if r.opts.ChangePolicy == ChangePolicyFollow {
+		return canonical, change, nil
+	}
+	return known, change, nil
+}
+
+// Invalidate 清除所有缓存的解析结果
+func (r *Resolver) Invalidate() {
+	r.mu.Lock()
+	r.cache = make(map[string]cacheEntry)
+	r.mu.Unlock()
+}
+
+// Forget 清除启动器已信任的仓库地址，下次解析的结果直接被信任。用于管理员修改启动器来源之后。
+func (r *Resolver) Forget(launcher string) error {
+	r.Invalidate()
+	if db.DB == nil {
+		return nil
+	}
+	if _, err := db.DB.Exec(`DELETE FROM repo_resolutions WHERE launcher = ?`, launcher); err != nil {
+		return fmt.Errorf("清除已知仓库失败: %w", err)
+	}
+	return nil
+}
+
+// KnownRepo 返回启动器已信任的仓库地址，没有记录时返回空字符串
+func (r *Resolver) KnownRepo(launcher string) (string, error) {
+	if db.DB == nil {
+		return "", nil
+	}
+	var repoURL sql.NullString
+	err := db.DB.QueryRow(`SELECT repo_url FROM repo_resolutions WHERE launcher = ?`, launcher).Scan(&repoURL)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取已知仓库失败: %w", err)
+	}
+	return repoURL.String, nil
+}
+
+// followRenames 请求仓库页面并跟随重定向，得到重命名或转移后的规范地址。
+// 请求失败时原样返回。
+func (r *Resolver) followRenames(repoURL string) string {
+	req, err := http.NewRequest(http.MethodHead, repoURL, nil)
+	if err != nil {
+		return repoURL
+	}
+	req.Header.Set("User-Agent", r.opts.UserAgent)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		log.Printf("检查仓库 %s 是否重命名失败: %v", repoURL, err)
+		return repoURL
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return repoURL
+	}
+	final, err := gh.NormalizeRepoURL(resp.Request.URL.String())
+	if err != nil {
+		return repoURL
+	}
+	return final
+}
+
+// checkChange 将解析结果与该启动器之前信任的仓库比较。
+// 如果旧仓库重定向到新仓库，视为正常的重命名/转移；否则发出告警，并按变更策略决定使用哪个仓库。
+func (r *Resolver) checkChange(launcher, repoURL string) (string, error) {
+	if db.DB == nil || launcher == "" {
+		return repoURL, nil
+	}
+	var known, candidate sql.NullString
+	err := db.DB.QueryRow(`SELECT repo_url, candidate_url FROM repo_resolutions WHERE launcher = ?`, launcher).Scan(&known, &candidate)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("读取已知仓库失败: %w", err)
+	}
+	if !known.Valid || known.String == "" {
+		return repoURL, r.saveResolution(launcher, repoURL, "")
+	}
+	if known.String == repoURL {
+		if candidate.String != "" {
+			return repoURL, r.saveResolution(launcher, repoURL, "")
+		}
+		return repoURL, nil
+	}
+
+	if r.followRenames(known.String) == repoURL {
+		events.Info(events.KindRepoRenamed, launcher, "仓库 %s 已重命名或转移至 %s", known.String, repoURL)
+		return repoURL, r.saveResolution(launcher, repoURL, "")
+	}
+
+	if candidate.String != repoURL {
+		// 同一个候选仓库只告警一次，避免每次扫描重复告警
+		events.Alert(events.KindRepoChanged, launcher, "源页面指向的仓库从 %s 变为 %s，可能遭到劫持（策略: %s）",
+			known.String, repoURL, r.opts.ChangePolicy)
+	}
+	if r.opts.ChangePolicy == ChangePolicyFollow {
+		return repoURL, r.saveResolution(launcher, repoURL, "")
+	}
+	return known.String, r.saveResolution(launcher, known.String, repoURL)
+}
+
+func (r *Resolver) saveResolution(launcher, repoURL, candidate string) error {
+	_, err := db.DB.Exec(`INSERT INTO repo_resolutions (launcher, repo_url, candidate_url, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+        ON CONFLICT(launcher) DO UPDATE SET repo_url = excluded.repo_url, candidate_url = excluded.candidate_url, updated_at = CURRENT_TIMESTAMP`,
+		launcher, repoURL, candidate)
+	if err != nil {
+		return fmt.Errorf("保存仓库解析结果失败: %w", err)
+	}
+	return nil
+}
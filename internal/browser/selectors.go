@@ -0,0 +1,203 @@
+package browser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+)
+
+var (
+	// 默认严格匹配：https://github.com/<owner>/<repo> 或 /<owner>/<repo>
+	defaultAbsRe = regexp.MustCompile(`^https://github\.com/[^/]+/[^/#?]+$`)
+	defaultRelRe = regexp.MustCompile(`^/[^/]+/[^/#?]+$`)
+	// 在任意文本（脚本、meta 内容、JSON 字符串）中查找仓库地址
+	embeddedRepoRe = regexp.MustCompile(`(?:https?://)?(?:www\.)?github\.com/[A-Za-z0-9-]+/[A-Za-z0-9._-]+`)
+)
+
+// extractRepoURL 使用单个选择器从页面中提取仓库地址。支持的选择器：
+//   - ""：第一个形如 https://github.com/<owner>/<repo> 或 /<owner>/<repo> 的锚点 href
+//   - "regex:<pattern>"：第一个匹配正则表达式的锚点 href
+//   - "json:<path>"：将页面解析为 JSON，按路径取值，例如 json:data.links[0].url
+//   - "xpath:<expr>"：XPath 表达式，取节点文本或属性值，例如 xpath://meta[@name='repo']/@content
+//   - "attr:<selector>@<attribute>"：CSS 选择器匹配元素的指定属性，例如 attr:meta[name=repo]@content
+//   - 其他：CSS 选择器，使用第一个 href 形如仓库地址的匹配元素
+//
+// json、xpath 与 attr 取到的值如果不是仓库地址本身，会在其中查找第一个 GitHub 仓库地址，
+// 因此也可以用来匹配脚本块中的内容。
+func extractRepoURL(p *page, selector string) (string, error) {
+	switch {
+	case strings.HasPrefix(selector, "regex:"):
+		re, err := regexp.Compile(strings.TrimPrefix(selector, "regex:"))
+		if err != nil {
+			return "", fmt.Errorf("repo_selector 中的正则表达式无效: %w", err)
+		}
+		return firstHref(p, "a", func(href string) (string, bool) {
+			if !re.MatchString(href) {
+				return "", false
+			}
+			if strings.HasPrefix(href, "/") {
+				return "https://github.com" + href, true
+			}
+			return href, true
+		})
+
+	case strings.HasPrefix(selector, "json:"):
+		return extractJSON(p, strings.TrimPrefix(selector, "json:"))
+
+	case strings.HasPrefix(selector, "xpath:"):
+		return extractXPath(p, strings.TrimPrefix(selector, "xpath:"))
+
+	case strings.HasPrefix(selector, "attr:"):
+		spec := strings.TrimPrefix(selector, "attr:")
+		at := strings.LastIndex(spec, "@")
+		if at <= 0 || at == len(spec)-1 {
+			return "", errors.New("attr 选择器格式应为 attr:<selector>@<attribute>")
+		}
+		css, attr := spec[:at], spec[at+1:]
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(p.Body))
+		if err != nil {
+			return "", fmt.Errorf("解析 HTML 失败: %w", err)
+		}
+		var found string
+		doc.Find(css).EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+			if v, ok := sel.Attr(attr); ok {
+				found = findRepoIn(v)
+			}
+			return found == ""
+		})
+		if found == "" {
+			return "", errors.New("没有匹配的元素属性")
+		}
+		return found, nil
+
+	default:
+		// 空选择器匹配所有锚点，否则视为 CSS 选择器；均对 href 应用默认的仓库地址匹配
+		css := selector
+		if css == "" {
+			css = "a"
+		}
+		return firstHref(p, css, func(href string) (string, bool) {
+			if defaultAbsRe.MatchString(href) {
+				return href, true
+			}
+			if defaultRelRe.MatchString(href) {
+				return "https://github.com" + href, true
+			}
+			return "", false
+		})
+	}
+}
+
+// firstHref 返回第一个被 accept 接受的匹配元素 href
+func firstHref(p *page, css string, accept func(href string) (string, bool)) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(p.Body))
+	if err != nil {
+		return "", fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+	var found string
+	doc.Find(css).EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		href := strings.TrimSpace(sel.AttrOr("href", ""))
+		if href == "" {
+			return true
+		}
+		if v, ok := accept(href); ok {
+			found = v
+			return false
+		}
+		return true
+	})
+	if found == "" {
+		return "", errors.New("没有匹配的链接")
+	}
+	return found, nil
+}
+
+func extractXPath(p *page, expr string) (string, error) {
+	doc, err := htmlquery.Parse(bytes.NewReader(p.Body))
+	if err != nil {
+		return "", fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+	nodes, err := htmlquery.QueryAll(doc, expr)
+	if err != nil {
+		return "", fmt.Errorf("无效的 XPath 表达式: %w", err)
+	}
+	for _, n := range nodes {
+		// 属性节点的 InnerText 即属性值；元素节点优先取 href，其次取文本
+		v := htmlquery.SelectAttr(n, "href")
+		if v == "" {
+			v = htmlquery.InnerText(n)
+		}
+		if found := findRepoIn(v); found != "" {
+			return found, nil
+		}
+	}
+	return "", errors.New("XPath 没有匹配到仓库地址")
+}
+
+func extractJSON(p *page, path string) (string, error) {
+	var doc any
+	if err := json.Unmarshal(p.Body, &doc); err != nil {
+		return "", fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+	v, err := lookupJSONPath(doc, path)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("JSON 路径 %s 的值不是字符串", path)
+	}
+	if found := findRepoIn(s); found != "" {
+		return found, nil
+	}
+	return "", fmt.Errorf("JSON 路径 %s 的值不包含仓库地址", path)
+}
+
+// lookupJSONPath 按点号分隔的路径取值，数组下标可写作 a[0] 或 a.0，可选的 "$." 前缀会被忽略
+func lookupJSONPath(doc any, path string) (any, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	cur := doc
+	if path == "" {
+		return cur, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("JSON 路径中不存在键 %q", key)
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("JSON 路径中的下标 %q 无效", key)
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("JSON 路径在 %q 处无法继续", key)
+		}
+	}
+	return cur, nil
+}
+
+// findRepoIn 返回文本中的第一个 GitHub 仓库地址
+func findRepoIn(s string) string {
+	m := embeddedRepoRe.FindString(strings.TrimSpace(s))
+	if m == "" {
+		return ""
+	}
+	if !strings.HasPrefix(m, "http") {
+		m = "https://" + m
+	}
+	return m
+}
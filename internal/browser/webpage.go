@@ -0,0 +1,193 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"lemwood_mirror/internal/downloader"
+	"lemwood_mirror/internal/version"
+)
+
+var (
+	// 未配置 asset_pattern 时，认为常见安装包与压缩包是资源文件
+	defaultAssetRe = regexp.MustCompile(`(?i)\.(apk|aab|jar|zip|7z|tar\.gz|tgz|tar\.xz|exe|msi|dmg|pkg|deb|rpm|appimage)$`)
+	// 未配置 version_pattern 时，从文件名中提取形如 1.2.3 的版本号
+	defaultVersionRe = regexp.MustCompile(`(?i)(\d+(?:\.\d+)+(?:-(?:alpha|beta|rc|pre)\.?\d*)?)`)
+)
+
+// WebSpec 描述如何从普通下载页或目录列表中提取版本与资源
+type WebSpec struct {
+	// LinkSelector 为候选链接的 CSS 选择器，默认 "a[href]"
+	LinkSelector string
+	// AssetPattern 匹配资源链接（绝对地址）的正则表达式
+	AssetPattern string
+	// VersionPattern 从资源文件名中提取版本号的正则表达式，使用第一个捕获组；
+	// 如果没有任何资源文件名匹配，则尝试匹配页面文本，此时页面上的全部资源都归属该版本
+	VersionPattern string
+	// Compare 为版本排序方案，为空时按自然顺序
+	Compare version.Comparator
+}
+
+// WebRelease 抓取网页来源的最新版本。页面上的资源按版本号分组，选择版本号最大的一组，
+// 并通过 HEAD 请求补全文件大小、类型与修改时间。
+func (r *Resolver) WebRelease(ctx context.Context, source string, spec WebSpec) (*downloader.Release, error) {
+	assetRe := defaultAssetRe
+	if spec.AssetPattern != "" {
+		re, err := regexp.Compile(spec.AssetPattern)
+		if err != nil {
+			return nil, fmt.Errorf("asset_pattern 中的正则表达式无效: %w", err)
+		}
+		assetRe = re
+	}
+	versionRe := defaultVersionRe
+	if spec.VersionPattern != "" {
+		re, err := regexp.Compile(spec.VersionPattern)
+		if err != nil {
+			return nil, fmt.Errorf("version_pattern 中的正则表达式无效: %w", err)
+		}
+		versionRe = re
+	}
+	linkSelector := spec.LinkSelector
+	if linkSelector == "" {
+		linkSelector = "a[href]"
+	}
+
+	p, err := fetchPageRetry(ctx, source, r.opts)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(p.Body))
+	if err != nil {
+		return nil, fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+
+	// 收集资源链接并按版本分组
+	seen := make(map[string]bool)
+	var links []*url.URL
+	doc.Find(linkSelector).Each(func(_ int, sel *goquery.Selection) {
+		href := strings.TrimSpace(sel.AttrOr("href", ""))
+		if href == "" {
+			return
+		}
+		u, err := p.URL.Parse(href)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		u.Fragment = ""
+		abs := u.String()
+		if seen[abs] || !assetRe.MatchString(abs) {
+			return
+		}
+		seen[abs] = true
+		links = append(links, u)
+	})
+	if len(links) == 0 {
+		return nil, errors.New("页面上没有匹配的资源链接")
+	}
+
+	groups := make(map[string][]*url.URL)
+	for _, u := range links {
+		if m := versionRe.FindStringSubmatch(assetName(u)); m != nil {
+			groups[firstGroup(m)] = append(groups[firstGroup(m)], u)
+		}
+	}
+	var tag string
+	var assets []*url.URL
+	if len(groups) > 0 {
+		versions := make([]string, 0, len(groups))
+		for v := range groups {
+			versions = append(versions, v)
+		}
+		cmp := spec.Compare
+		if cmp == nil {
+			cmp = func(a, b version.Info) int { return version.Loose(a.Tag, b.Tag) }
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return cmp(version.Info{Tag: versions[i]}, version.Info{Tag: versions[j]}) > 0
+		})
+		tag = versions[0]
+		assets = groups[tag]
+	} else if m := versionRe.FindStringSubmatch(doc.Text()); m != nil {
+		tag = firstGroup(m)
+		assets = links
+	} else {
+		return nil, errors.New("无法从资源文件名或页面中提取版本号")
+	}
+
+	// 版本号用作目录名，来自页面的内容不能逃出存储目录
+	if err := downloader.ValidName(tag); err != nil {
+		return nil, fmt.Errorf("提取的版本号无效: %w", err)
+	}
+	rel := &downloader.Release{
+		TagName:    tag,
+		Name:       tag,
+		HTMLURL:    p.URL.String(),
+		Prerelease: strings.Contains(tag, "-"),
+	}
+	for _, u := range assets {
+		a := downloader.Asset{Name: assetName(u), URL: u.String()}
+		if err := downloader.ValidName(a.Name); err != nil {
+			log.Printf("跳过资源 %s: %v", u, err)
+			continue
+		}
+		r.probeAsset(ctx, &a)
+		if a.UpdatedAt.After(rel.PublishedAt) {
+			rel.PublishedAt = a.UpdatedAt
+		}
+		rel.Assets = append(rel.Assets, a)
+	}
+	if len(rel.Assets) == 0 {
+		return nil, errors.New("页面上没有文件名有效的资源链接")
+	}
+	// 没有 Last-Modified 时发布时间保持未知，不能用当前时间代替，否则每次扫描得到的发布时间都不同
+	return rel, nil
+}
+
+// probeAsset 通过 HEAD 请求补全资源的大小、类型与修改时间，失败时保留未知值
+func (r *Resolver) probeAsset(ctx context.Context, a *downloader.Asset) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, a.URL, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", r.opts.UserAgent)
+	resp, err := r.client.Do(req)
+	if err != nil {
+		log.Printf("获取资源 %s 信息失败: %v", a.URL, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("获取资源 %s 信息失败，状态码: %d", a.URL, resp.StatusCode)
+		return
+	}
+	if resp.ContentLength > 0 {
+		a.Size = int(resp.ContentLength)
+	}
+	a.ContentType = resp.Header.Get("Content-Type")
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		a.UpdatedAt = lm
+	}
+}
+
+// assetName 返回链接的文件名。u.Path 已经解码过，不能再次解码，否则 %252f 会变成路径分隔符
+func assetName(u *url.URL) string {
+	return path.Base(u.Path)
+}
+
+func firstGroup(m []string) string {
+	if len(m) > 1 && m[1] != "" {
+		return m[1]
+	}
+	return m[0]
+}
@@ -7,23 +7,124 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // LauncherConfig 描述如何从源页面发现启动器的 GitHub 仓库 URL。
 // 如果 RepoSelector 以 "regex:" 开头，它将被视为正则表达式来匹配锚点 href。
+// 以 "json:"、"xpath:"、"attr:" 开头时分别按 JSON 路径、XPath 表达式、CSS 选择器加属性名取值。
 // 如果 RepoSelector 为空，则使用第一个包含 "github.com" 的锚点 href。
+// RepoSelectors 是在 RepoSelector 之后按顺序尝试的备用选择器。
 // SourceURL 可以直接是 GitHub 仓库 URL（例如 https://github.com/owner/repo），在这种情况下选择器被忽略。
+//
+// SourceType 为 "webpage" 时，SourceURL 被视为普通下载页或目录列表：
+// 页面上匹配 AssetPattern 的链接作为资源，VersionPattern 从文件名中提取版本号，选择最大的版本镜像。
 
 type LauncherConfig struct {
-	Name         string `json:"name"`
-	SourceURL    string `json:"source_url"`
-	RepoSelector string `json:"repo_selector"`
+	Name           string   `json:"name"`
+	SourceURL      string   `json:"source_url"`
+	RepoSelector   string   `json:"repo_selector"`
+	RepoSelectors  []string `json:"repo_selectors,omitempty"`
+	SourceType     string   `json:"source_type,omitempty"`     // "github"（默认）或 "webpage"
+	LinkSelector   string   `json:"link_selector,omitempty"`   // webpage：候选链接的 CSS 选择器
+	AssetPattern   string   `json:"asset_pattern,omitempty"`   // webpage：匹配资源链接的正则
+	VersionPattern string   `json:"version_pattern,omitempty"` // webpage：提取版本号的正则，使用第一个捕获组
+
+	VersionScheme     string `json:"version_scheme,omitempty"`      // 版本排序方案：loose（默认）、semver、build、calver、pep440、date、regex
+	VersionRegex      string `json:"version_regex,omitempty"`       // regex 方案的正则表达式
+	VersionRegexOrder []int  `json:"version_regex_order,omitempty"` // regex 方案按优先级比较的捕获组序号
+	KeepVersions      int    `json:"keep_versions,omitempty"`       // 保留的版本数，覆盖全局设置
+	Promotion         string `json:"promotion,omitempty"`           // 新版本成为最新版本的方式：auto（默认）、soak、manual
+	SoakHours         int    `json:"soak_hours,omitempty"`          // soak 方式下新版本的观察时长（小时）
+	Disabled          bool   `json:"disabled,omitempty"`            // 禁用后不再扫描，已镜像的版本继续提供下载
+	QuotaMB           int    `json:"quota_mb,omitempty"`            // 该启动器目录的磁盘配额（MB），0 表示不限制
+
+	CheckCron            string `json:"check_cron,omitempty"`             // 该启动器单独的检查计划，覆盖全局 check_cron
+	CheckIntervalMinutes int    `json:"check_interval_minutes,omitempty"` // 按固定间隔检查，不能与 check_cron 同时使用
+	CheckJitterSeconds   int    `json:"check_jitter_seconds,omitempty"`   // 每次检查前的随机延迟上限，覆盖全局设置
+	AdaptivePolling      bool   `json:"adaptive_polling,omitempty"`       // 按上游发布节奏自动调整检查间隔
+}
+
+// Keep 返回启动器保留的版本数，未单独配置时使用全局设置
+func (l LauncherConfig) Keep(global int) int {
+	if l.KeepVersions > 0 {
+		return l.KeepVersions
+	}
+	return global
+}
+
+// 启动器来源类型
+const (
+	SourceGitHub  = "github"
+	SourceWebpage = "webpage"
+)
+
+// 新版本的发布方式
+const (
+	PromotionAuto   = "auto"
+	PromotionSoak   = "soak"
+	PromotionManual = "manual"
+)
+
+// 空间不足时的处理方式
+const (
+	QuotaRefuse = "refuse" // 拒绝下载新版本
+	QuotaPrune  = "prune"  // 先按保留策略为新版本腾出空间，仍不足时拒绝
+)
+
+// IsWebpage 判断启动器是否从普通网页获取
+func (l LauncherConfig) IsWebpage() bool {
+	return l.SourceType == SourceWebpage
+}
+
+// Selectors 返回按顺序尝试的全部仓库选择器
+func (l LauncherConfig) Selectors() []string {
+	selectors := []string{l.RepoSelector}
+	for _, sel := range l.RepoSelectors {
+		if sel != "" {
+			selectors = append(selectors, sel)
+		}
+	}
+	if l.RepoSelector == "" && len(selectors) > 1 {
+		// 配置了备用选择器时，默认锚点匹配作为最后的兜底
+		selectors = append(selectors[1:], "")
+	}
+	return selectors
+}
+
+// DownloadMirror 描述一个上游下载策略：
+// type 为 "direct" 时直接从 GitHub 下载；"proxy" 时将 url 作为前缀拼接到下载地址前；
+// "xget" 时将 https://github.com/ 替换为 <url>/gh/。
+type DownloadMirror struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	URL  string `json:"url,omitempty"`
+}
+
+// DownloadWindow 是允许下载大资源的每日时间段，格式为 "HH:MM"，end 早于 start 时表示跨越午夜
+type DownloadWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// RetryConfig 为下载、GitHub API 与仓库解析共用的重试策略，未设置的字段使用默认值
+type RetryConfig struct {
+	MaxAttempts          int      `json:"max_attempts,omitempty"`            // 最多尝试次数，默认 4
+	BaseDelaySeconds     float64  `json:"base_delay_seconds,omitempty"`      // 首次重试前的等待，之后每次翻倍，默认 2
+	MaxDelaySeconds      float64  `json:"max_delay_seconds,omitempty"`       // 单次退避上限，默认 60
+	Jitter               *float64 `json:"jitter,omitempty"`                  // 随机抖动比例（0~1），未配置时为 0.5，0 表示不抖动
+	MaxRetryAfterMinutes int      `json:"max_retry_after_minutes,omitempty"` // 服务端要求等待超过该值时放弃，默认 15
+	ScanBudget           int      `json:"scan_budget,omitempty"`             // 每次扫描的重试总次数上限，0 表示不限制
 }
 
 type Config struct {
 	ServerAddress          string           `json:"server_address"`
 	ServerPort             int              `json:"server_port"`
 	CheckCron              string           `json:"check_cron"`
+	CheckJitterSeconds     int              `json:"check_jitter_seconds,omitempty"`          // 每次检查前的随机延迟上限（秒）
+	AdaptiveMinMinutes     int              `json:"adaptive_min_interval_minutes,omitempty"` // 自适应检查的最短间隔，默认 10
+	AdaptiveMaxMinutes     int              `json:"adaptive_max_interval_minutes,omitempty"` // 自适应检查的最长间隔，默认 720
+	StaleAfterHours        int              `json:"stale_after_hours,omitempty"`             // 启动器未能同步上游超过该时长后告警，默认 24，-1 表示不告警
 	StoragePath            string           `json:"storage_path"`
 	GitHubToken            string           `json:"github_token"`
 	ProxyURL               string           `json:"proxy_url"`
@@ -32,10 +133,59 @@ type Config struct {
 	XgetEnabled            bool             `json:"xget_enabled"`
 	DownloadTimeoutMinutes int              `json:"download_timeout_minutes"`
 	ConcurrentDownloads    int              `json:"concurrent_downloads"`
+	PerHostDownloads       int              `json:"per_host_downloads,omitempty"` // 同一上游主机的并发下载上限，0 表示与 concurrent_downloads 相同
 	DownloadUrlBase        string           `json:"download_url_base,omitempty"`
+	UserAgent              string           `json:"user_agent,omitempty"`
+	ResolverTimeoutSeconds int              `json:"resolver_timeout_seconds,omitempty"`
+	ResolverCacheMinutes   int              `json:"resolver_cache_ttl_minutes,omitempty"`
+	RepoChangePolicy       string           `json:"repo_change_policy,omitempty"` // "block"（默认）或 "follow"
+	DownloadMirrors        []DownloadMirror `json:"download_mirrors,omitempty"`
+	MirrorCheckMinutes     int              `json:"mirror_health_check_minutes,omitempty"`
+	MirrorProbeURL         string           `json:"mirror_probe_url,omitempty"`
+	BandwidthLimitKBps     int              `json:"bandwidth_limit_kbps,omitempty"` // 所有下载共享的带宽上限（KB/s），0 表示不限速
+	DownloadWindows        []DownloadWindow `json:"download_windows,omitempty"`
+	WindowMinSizeMB        int              `json:"window_min_asset_size_mb,omitempty"` // 小于该大小的资源不受时间窗口限制
+	WindowBypassLatest     bool             `json:"window_bypass_latest,omitempty"`     // 最新版本的资源不受时间窗口限制
+	Retry                  RetryConfig      `json:"retry,omitempty"`
+	KeepVersions           int              `json:"keep_versions,omitempty"`         // 每个启动器保留的版本数，0 表示全部保留
+	DiskQuotaMB            int              `json:"disk_quota_mb,omitempty"`         // 存储目录的总配额（MB），0 表示不限制
+	MinFreeSpaceMB         int              `json:"min_free_space_mb,omitempty"`     // 下载后文件系统至少保留的可用空间（MB），默认 512，-1 表示不检查
+	QuotaPolicy            string           `json:"quota_policy,omitempty"`          // 空间不足时的处理方式："refuse"（默认）或 "prune"
+	TorrentTrackers        []string         `json:"torrent_trackers,omitempty"`      // 写入生成的种子的 tracker 地址
+	AdminToken             string           `json:"admin_token,omitempty"`           // 管理接口的访问令牌，为空时管理接口不可用
+	WebhookSecret          string           `json:"github_webhook_secret,omitempty"` // GitHub webhook 的签名密钥，为空时不接收 webhook
+	Federation             FederationConfig `json:"federation,omitempty"`
+	Replica                ReplicaConfig    `json:"replica,omitempty"`
 	Launchers              []LauncherConfig `json:"launchers"`
 }
 
+// FederationConfig 描述同一镜像网络中的其他节点，下载请求按客户端所在国家重定向到合适的节点
+type FederationConfig struct {
+	Peers          []PeerConfig `json:"peers,omitempty"`
+	LocalCountries []string     `json:"local_countries,omitempty"`        // 这些国家的客户端总是由本节点提供
+	CheckMinutes   int          `json:"check_interval_minutes,omitempty"` // 节点健康与内容检查的间隔，默认 5 分钟
+}
+
+// ReplicaConfig 使本实例作为副本运行：从主节点同步版本，不再访问 GitHub
+type ReplicaConfig struct {
+	PrimaryURL  string `json:"primary_url,omitempty"`           // 主节点根地址，为空时不启用副本模式
+	PollMinutes int    `json:"poll_interval_minutes,omitempty"` // 轮询主节点的间隔，默认 5 分钟
+}
+
+// Enabled 判断是否以副本模式运行
+func (r ReplicaConfig) Enabled() bool {
+	return r.PrimaryURL != ""
+}
+
+// PeerConfig 是联邦中的一个镜像节点
+type PeerConfig struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`                 // 节点根地址，例如 https://hk.example.com
+	Region    string   `json:"region,omitempty"`    // 节点所在区域，仅用于展示
+	Countries []string `json:"countries,omitempty"` // 由该节点提供的国家（ISO 3166 代码或名称），为空时作为其他国家的后备节点
+	Weight    int      `json:"weight,omitempty"`    // 同时满足条件的节点之间按权重分配，默认 1
+}
+
 func LoadConfig(projectRoot string) (*Config, error) {
 	cfgPath := filepath.Join(projectRoot, "config.json")
 	f, err := os.Open(cfgPath)
@@ -54,12 +204,116 @@ func LoadConfig(projectRoot string) (*Config, error) {
 	if cfg.StoragePath == "" {
 		return nil, errors.New("config.storage_path 不能为空")
 	}
+	names := make(map[string]bool, len(cfg.Launchers))
+	for _, l := range cfg.Launchers {
+		if err := l.Validate(); err != nil {
+			return nil, err
+		}
+		if names[l.Name] {
+			return nil, fmt.Errorf("启动器 %s 重复定义", l.Name)
+		}
+		names[l.Name] = true
+	}
 	if cfg.CheckCron == "" {
 		cfg.CheckCron = "*/10 * * * *" // 默认每 10 分钟
 	}
+	if cfg.StaleAfterHours == 0 {
+		cfg.StaleAfterHours = 24
+	}
+	if cfg.AdaptiveMinMinutes <= 0 {
+		cfg.AdaptiveMinMinutes = 10
+	}
+	if cfg.AdaptiveMaxMinutes <= 0 {
+		cfg.AdaptiveMaxMinutes = 720
+	}
+	if cfg.MinFreeSpaceMB == 0 {
+		cfg.MinFreeSpaceMB = 512
+	}
+	switch cfg.QuotaPolicy {
+	case "":
+		cfg.QuotaPolicy = QuotaRefuse
+	case QuotaRefuse, QuotaPrune:
+	default:
+		return nil, fmt.Errorf("config.quota_policy 无效: %q", cfg.QuotaPolicy)
+	}
+	if cfg.DiskQuotaMB < 0 {
+		return nil, errors.New("config.disk_quota_mb 不能为负数")
+	}
+	if err := cfg.Federation.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Federation.CheckMinutes <= 0 {
+		cfg.Federation.CheckMinutes = 5
+	}
+	if cfg.Replica.Enabled() {
+		if !strings.HasPrefix(cfg.Replica.PrimaryURL, "http://") && !strings.HasPrefix(cfg.Replica.PrimaryURL, "https://") {
+			return nil, fmt.Errorf("config.replica.primary_url 无效: %q", cfg.Replica.PrimaryURL)
+		}
+		cfg.Replica.PrimaryURL = strings.TrimRight(cfg.Replica.PrimaryURL, "/")
+	}
+	if cfg.Replica.PollMinutes <= 0 {
+		cfg.Replica.PollMinutes = 5
+	}
+	if cfg.MirrorCheckMinutes == 0 {
+		cfg.MirrorCheckMinutes = 10
+	}
+	if cfg.MirrorProbeURL == "" {
+		cfg.MirrorProbeURL = "https://github.com/robots.txt"
+	}
 	// 允许环境变量覆盖 GitHub 令牌
 	if env := os.Getenv("GITHUB_TOKEN"); env != "" {
 		cfg.GitHubToken = env
 	}
+	if env := os.Getenv("ADMIN_TOKEN"); env != "" {
+		cfg.AdminToken = env
+	}
+	if env := os.Getenv("GITHUB_WEBHOOK_SECRET"); env != "" {
+		cfg.WebhookSecret = env
+	}
 	return &cfg, nil
 }
+
+// validate 检查联邦节点的名称与地址
+func (f *FederationConfig) validate() error {
+	names := make(map[string]bool, len(f.Peers))
+	for i := range f.Peers {
+		p := &f.Peers[i]
+		if p.Name == "" {
+			return fmt.Errorf("federation.peers[%d] 缺少 name", i)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("联邦节点 %s 重复定义", p.Name)
+		}
+		names[p.Name] = true
+		if !strings.HasPrefix(p.URL, "http://") && !strings.HasPrefix(p.URL, "https://") {
+			return fmt.Errorf("联邦节点 %s 的 url 无效: %q", p.Name, p.URL)
+		}
+		p.URL = strings.TrimRight(p.URL, "/")
+		if p.Weight < 0 {
+			return fmt.Errorf("联邦节点 %s 的 weight 不能为负数", p.Name)
+		}
+		if p.Weight == 0 {
+			p.Weight = 1
+		}
+	}
+	return nil
+}
+
+// PublicBaseURL 返回镜像站对外访问的根地址（不带结尾斜杠），用于生成订阅源等绝对链接。
+// 优先使用 download_url_base，其次使用 server_address 与 server_port；都未配置时返回空字符串。
+func (c *Config) PublicBaseURL() string {
+	base := c.DownloadUrlBase
+	if base == "" && c.ServerAddress != "" {
+		base = c.ServerAddress
+		if c.ServerPort != 0 && c.ServerPort != 80 && c.ServerPort != 443 {
+			base = fmt.Sprintf("%s:%d", strings.TrimRight(base, "/"), c.ServerPort)
+		}
+	}
+	if base == "" {
+		return ""
+	}
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	return strings.TrimRight(base, "/")
+}
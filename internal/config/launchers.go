@@ -0,0 +1,203 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrLauncherNotFound = errors.New("启动器不存在")
+	ErrLauncherExists   = errors.New("启动器已存在")
+)
+
+// Validate 检查启动器定义的必填字段与取值范围，不访问网络
+func (l LauncherConfig) Validate() error {
+	if l.Name == "" {
+		return errors.New("name 不能为空")
+	}
+	// 启动器名称会作为存储目录名与 URL 路径的一部分
+	if strings.ContainsAny(l.Name, `/\`) || strings.HasPrefix(l.Name, ".") {
+		return fmt.Errorf("name 无效: %q", l.Name)
+	}
+	if l.SourceURL == "" {
+		return fmt.Errorf("启动器 %s 的 source_url 不能为空", l.Name)
+	}
+	switch l.SourceType {
+	case "", SourceGitHub, SourceWebpage:
+	default:
+		return fmt.Errorf("启动器 %s 的 source_type 无效: %q", l.Name, l.SourceType)
+	}
+	switch l.Promotion {
+	case "", PromotionAuto, PromotionManual:
+	case PromotionSoak:
+		if l.SoakHours <= 0 {
+			return fmt.Errorf("启动器 %s 的 soak_hours 必须大于 0", l.Name)
+		}
+	default:
+		return fmt.Errorf("启动器 %s 的 promotion 无效: %q", l.Name, l.Promotion)
+	}
+	if l.CheckCron != "" {
+		if l.CheckIntervalMinutes > 0 || l.AdaptivePolling {
+			return fmt.Errorf("启动器 %s 的 check_cron 不能与 check_interval_minutes 或 adaptive_polling 同时使用", l.Name)
+		}
+		if _, err := cron.ParseStandard(l.CheckCron); err != nil {
+			return fmt.Errorf("启动器 %s 的 check_cron 无效: %w", l.Name, err)
+		}
+	}
+	if l.CheckIntervalMinutes < 0 || l.CheckJitterSeconds < 0 {
+		return fmt.Errorf("启动器 %s 的检查间隔与随机延迟不能为负数", l.Name)
+	}
+	if l.QuotaMB < 0 {
+		return fmt.Errorf("启动器 %s 的 quota_mb 不能为负数", l.Name)
+	}
+	return nil
+}
+
+// Registry 保存运行期的启动器定义，修改会原子地写回 config.json
+type Registry struct {
+	// OnChange 在启动器被添加、修改或删除并写回配置后调用，删除时 ok 为 false
+	OnChange func(l LauncherConfig, ok bool)
+
+	path      string
+	mu        sync.RWMutex
+	launchers []LauncherConfig
+}
+
+// NewRegistry 使用 LoadConfig 读出的启动器列表创建 Registry，修改写回 projectRoot 下的 config.json
+func NewRegistry(projectRoot string, launchers []LauncherConfig) *Registry {
+	return &Registry{
+		path:      filepath.Join(projectRoot, "config.json"),
+		launchers: append([]LauncherConfig(nil), launchers...),
+	}
+}
+
+// List 返回全部启动器定义（包括已禁用的）
+func (r *Registry) List() []LauncherConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]LauncherConfig(nil), r.launchers...)
+}
+
+// Get 按名称查找启动器定义
+func (r *Registry) Get(name string) (LauncherConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, l := range r.launchers {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return LauncherConfig{}, false
+}
+
+// Create 添加启动器并写回配置文件
+func (r *Registry) Create(l LauncherConfig) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	if r.index(l.Name) >= 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrLauncherExists, l.Name)
+	}
+	err := r.save(append(append([]LauncherConfig(nil), r.launchers...), l))
+	r.mu.Unlock()
+	r.notify(l, true, err)
+	return err
+}
+
+// Update 替换同名启动器的定义并写回配置文件
+func (r *Registry) Update(l LauncherConfig) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	i := r.index(l.Name)
+	if i < 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrLauncherNotFound, l.Name)
+	}
+	list := append([]LauncherConfig(nil), r.launchers...)
+	list[i] = l
+	err := r.save(list)
+	r.mu.Unlock()
+	r.notify(l, true, err)
+	return err
+}
+
+// Delete 删除启动器定义并写回配置文件，已镜像的文件不受影响
+func (r *Registry) Delete(name string) error {
+	r.mu.Lock()
+	i := r.index(name)
+	if i < 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrLauncherNotFound, name)
+	}
+	removed := r.launchers[i]
+	list := append(append([]LauncherConfig(nil), r.launchers[:i]...), r.launchers[i+1:]...)
+	err := r.save(list)
+	r.mu.Unlock()
+	r.notify(removed, false, err)
+	return err
+}
+
+// notify 在保存成功后调用 OnChange，调用时不持有 mu
+func (r *Registry) notify(l LauncherConfig, ok bool, err error) {
+	if err == nil && r.OnChange != nil {
+		r.OnChange(l, ok)
+	}
+}
+
+// index 返回启动器在列表中的位置，调用方需持有 mu
+func (r *Registry) index(name string) int {
+	for i, l := range r.launchers {
+		if l.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// save 将 launchers 写回配置文件，成功后替换内存中的列表，调用方需持有 mu。
+// 只替换文件中的 launchers 字段，其余配置（以及环境变量覆盖的令牌）保持原样；
+// 先写临时文件再重命名，写入失败不会损坏原配置。
+func (r *Registry) save(launchers []LauncherConfig) error {
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("读取 config.json 失败: %w", err)
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("解析 config.json 失败: %w", err)
+	}
+	list, err := json.Marshal(launchers)
+	if err != nil {
+		return err
+	}
+	raw["launchers"] = list
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(r.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, append(out, '\n'), mode); err != nil {
+		return fmt.Errorf("写入 config.json 失败: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入 config.json 失败: %w", err)
+	}
+	r.launchers = launchers
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -20,7 +21,8 @@ func InitDB(storagePath string) error {
 	}
 
 	var err error
-	DB, err = sql.Open("sqlite", dbPath)
+	// 下载队列、事件、扫描记录等会并发写入：WAL 允许读写并发，busy_timeout 让写入等待锁而不是立即返回 SQLITE_BUSY
+	DB, err = sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
 	if err != nil {
 		return fmt.Errorf("打开数据库失败: %w", err)
 	}
@@ -53,10 +55,110 @@ func createTables() error {
             ip TEXT,
             country TEXT,
             created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS events (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            level TEXT,
+            kind TEXT,
+            launcher TEXT,
+            message TEXT,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS repo_resolutions (
+            launcher TEXT PRIMARY KEY,
+            repo_url TEXT,
+            candidate_url TEXT,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS download_jobs (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            launcher TEXT NOT NULL,
+            version TEXT NOT NULL,
+            name TEXT NOT NULL,
+            url TEXT,
+            dir TEXT,
+            host TEXT,
+            size INTEGER DEFAULT 0,
+            is_latest INTEGER DEFAULT 0,
+            status TEXT,
+            attempts INTEGER DEFAULT 0,
+            strategy TEXT,
+            bytes INTEGER DEFAULT 0,
+            error TEXT,
+            not_before INTEGER DEFAULT 0,
+            created_at INTEGER,
+            updated_at INTEGER,
+            UNIQUE(launcher, version, name)
+        )`,
+		`CREATE TABLE IF NOT EXISTS latest_switches (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            launcher TEXT NOT NULL,
+            from_version TEXT,
+            to_version TEXT NOT NULL,
+            reason TEXT,
+            switched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS version_overrides (
+            launcher TEXT NOT NULL,
+            version TEXT NOT NULL,
+            yanked INTEGER DEFAULT 0,
+            yank_reason TEXT DEFAULT '',
+            note TEXT DEFAULT '',
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            PRIMARY KEY(launcher, version)
+        )`,
+		`CREATE TABLE IF NOT EXISTS pending_versions (
+            launcher TEXT NOT NULL,
+            version TEXT NOT NULL,
+            mirrored_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            promote_after DATETIME,
+            PRIMARY KEY(launcher, version)
+        )`,
+		`CREATE TABLE IF NOT EXISTS latest_pins (
+            launcher TEXT PRIMARY KEY,
+            version TEXT NOT NULL,
+            updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS scans (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            launcher TEXT NOT NULL,
+            started_at DATETIME NOT NULL,
+            finished_at DATETIME,
+            upstream_version TEXT,
+            outcome TEXT NOT NULL,
+            error TEXT,
+            bytes INTEGER DEFAULT 0
+        )`,
+		`CREATE TABLE IF NOT EXISTS blobs (
+            sha256 TEXT PRIMARY KEY,
+            size INTEGER NOT NULL,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )`,
+		`CREATE TABLE IF NOT EXISTS blob_refs (
+            launcher TEXT NOT NULL,
+            version TEXT NOT NULL,
+            name TEXT NOT NULL,
+            sha256 TEXT NOT NULL,
+            PRIMARY KEY(launcher, version, name)
+        )`,
+		`CREATE TABLE IF NOT EXISTS redirects (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            peer TEXT NOT NULL,
+            launcher TEXT,
+            version TEXT,
+            file_name TEXT,
+            country TEXT,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
         )`,
 		`CREATE INDEX IF NOT EXISTS idx_visits_created_at ON visits(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_downloads_created_at ON downloads(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_downloads_file_name ON downloads(file_name)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_kind ON events(kind)`,
+		`CREATE INDEX IF NOT EXISTS idx_latest_switches_launcher ON latest_switches(launcher)`,
+		`CREATE INDEX IF NOT EXISTS idx_scans_launcher ON scans(launcher, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_download_jobs_status ON download_jobs(status, not_before)`,
+		`CREATE INDEX IF NOT EXISTS idx_blob_refs_sha256 ON blob_refs(sha256)`,
+		`CREATE INDEX IF NOT EXISTS idx_redirects_peer ON redirects(peer, created_at)`,
 	}
 
 	for _, query := range queries {
@@ -64,5 +166,15 @@ func createTables() error {
 			return fmt.Errorf("创建表失败: %w, query: %s", err, query)
 		}
 	}
+
+	// 旧数据库的表中缺少后来新增的列，逐个补上；列已存在时忽略
+	columns := []string{
+		`ALTER TABLE download_jobs ADD COLUMN digest TEXT DEFAULT ''`,
+	}
+	for _, query := range columns {
+		if _, err := DB.Exec(query); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return fmt.Errorf("更新表结构失败: %w, query: %s", err, query)
+		}
+	}
 	return nil
 }
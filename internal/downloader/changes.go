@@ -0,0 +1,213 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupName 是每个启动器目录下保存被上游替换的旧资源的隐藏目录名
+const BackupName = ".backup"
+
+// readPublished 读取已发布版本目录中的 index.json
+func readPublished(final string) (*ReleaseInfo, error) {
+	b, err := os.ReadFile(filepath.Join(final, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	var info ReleaseInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, fmt.Errorf("解析 index.json 失败: %w", err)
+	}
+	return &info, nil
+}
+
+// assetChange 比较已镜像资源与上游资源，返回变化原因，未变化时返回空字符串。
+// 旧的 index.json 没有记录某个字段时不比较该字段，避免升级后把所有资源都重新下载一遍。
+func assetChange(old ReleaseAssetSimple, a Asset) string {
+	switch {
+	case old.UpstreamID != 0 && a.ID != 0 && old.UpstreamID != a.ID:
+		return fmt.Sprintf("上游资源 ID 由 %d 变为 %d", old.UpstreamID, a.ID)
+	case old.UpstreamDigest != "" && a.Digest != "" && old.UpstreamDigest != a.Digest:
+		return fmt.Sprintf("上游摘要由 %s 变为 %s", old.UpstreamDigest, a.Digest)
+	case !old.UpdatedAt.IsZero() && !a.UpdatedAt.IsZero() && !old.UpdatedAt.Equal(a.UpdatedAt):
+		return fmt.Sprintf("上游更新时间由 %s 变为 %s", old.UpdatedAt.Format(time.RFC3339), a.UpdatedAt.Format(time.RFC3339))
+	}
+	return ""
+}
+
+// knownSizes 返回资源列表的副本：上游未提供大小（如下载页的 HEAD 请求失败）且未被替换的资源，
+// 沿用已发布 index.json 中记录的实际大小，避免每次扫描都重新下载并让配额预留到正确的字节数
+func knownSizes(assets []Asset, prev *ReleaseInfo, changed map[string]string) []Asset {
+	out := append([]Asset(nil), assets...)
+	if prev == nil {
+		return out
+	}
+	sizes := make(map[string]int, len(prev.Assets))
+	for _, a := range prev.Assets {
+		sizes[a.Name] = a.Size
+	}
+	for i := range out {
+		a := &out[i]
+		if a.Size <= 0 && changed[a.Name] == "" && sizes[a.Name] > 0 {
+			a.Size = sizes[a.Name]
+		}
+	}
+	return out
+}
+
+// changedAssets 返回已发布版本中被上游替换的资源及原因，prev 为 nil 时返回空
+func changedAssets(prev *ReleaseInfo, assets []Asset) map[string]string {
+	changed := make(map[string]string)
+	if prev == nil {
+		return changed
+	}
+	old := make(map[string]ReleaseAssetSimple, len(prev.Assets))
+	for _, a := range prev.Assets {
+		old[a.Name] = a
+	}
+	for _, a := range assets {
+		if o, ok := old[a.Name]; ok {
+			if reason := assetChange(o, a); reason != "" {
+				changed[a.Name] = reason
+			}
+		}
+	}
+	return changed
+}
+
+// AssetsChanged 判断已发布的版本中是否有资源在上游被重新上传，调用方据此决定是否重新镜像同一版本
+func AssetsChanged(destBase, launcher string, rel *Release) bool {
+	prev, err := readPublished(filepath.Join(destBase, launcher, rel.Version()))
+	if err != nil {
+		return false
+	}
+	return len(changedAssets(prev, rel.Assets)) > 0
+}
+
+// backupAsset 将已发布的旧资源硬链接（不支持时复制）到 <base>/<launcher>/.backup/<version>/<name>.<时间>，返回备份路径
+func backupAsset(destBase, launcher, version, final, name string, now time.Time) (string, error) {
+	src := filepath.Join(final, name)
+	if _, err := os.Stat(src); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(destBase, launcher, BackupName, version)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(dir, name+"."+now.Format("20060102-150405"))
+	if err := os.Link(src, dst); err == nil {
+		return dst, nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// contentChanged 返回 SHA-256 与已发布 index.json 中记录的值不同的资源及原因
+func contentChanged(prev *ReleaseInfo, assets []ReleaseAssetSimple) map[string]string {
+	changed := make(map[string]string)
+	if prev == nil {
+		return changed
+	}
+	old := make(map[string]string, len(prev.Assets))
+	for _, a := range prev.Assets {
+		old[a.Name] = a.SHA256
+	}
+	for _, a := range assets {
+		if sum := old[a.Name]; sum != "" && a.SHA256 != "" && sum != a.SHA256 {
+			changed[a.Name] = fmt.Sprintf("重新下载的文件 SHA-256 由 %s 变为 %s", sum, a.SHA256)
+		}
+	}
+	return changed
+}
+
+// fileSHA256 计算文件的 SHA-256，返回十六进制字符串
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkDigest 将本地 SHA-256 与上游摘要比较，上游未提供摘要或使用其他算法时不检查
+func checkDigest(name, sum, upstream string) error {
+	algo, want, ok := strings.Cut(upstream, ":")
+	if !ok || !strings.EqualFold(algo, "sha256") {
+		return nil
+	}
+	if !strings.EqualFold(want, sum) {
+		return fmt.Errorf("资源 %s 的 SHA-256 与上游摘要不一致 (本地: %s, 上游: %s)", name, sum, want)
+	}
+	return nil
+}
+
+// hashAssets 计算暂存目录中各资源的 SHA-256 并与上游摘要核对。
+// 未被替换且大小一致的资源沿用已发布 index.json 中的值，避免每次都读取整个文件；force 为 true 时全部重新计算。
+func hashAssets(dir string, assets []ReleaseAssetSimple, prev *ReleaseInfo, changed map[string]string, force bool) error {
+	known := make(map[string]ReleaseAssetSimple)
+	if prev != nil {
+		for _, a := range prev.Assets {
+			known[a.Name] = a
+		}
+	}
+	for i := range assets {
+		a := &assets[i]
+		if a.UpstreamURL == "" {
+			continue
+		}
+		if o, ok := known[a.Name]; ok && !force && o.SHA256 != "" && o.Size == a.Size && changed[a.Name] == "" {
+			a.SHA256 = o.SHA256
+		} else {
+			sum, err := fileSHA256(filepath.Join(dir, a.Name))
+			if err != nil {
+				return fmt.Errorf("计算资源 %s 的 SHA-256 失败: %w", a.Name, err)
+			}
+			a.SHA256 = sum
+		}
+		if err := checkDigest(a.Name, a.SHA256, a.UpstreamDigest); err != nil {
+			// 删除不一致的文件，下次扫描重新下载
+			os.Remove(filepath.Join(dir, a.Name))
+			log.Printf("%v，已删除", err)
+			return err
+		}
+	}
+	return nil
+}
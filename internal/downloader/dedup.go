@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"lemwood_mirror/internal/blobs"
+)
+
+// linkBlobs 将暂存目录中的资源纳入 blob 存储，返回节省的字节数。去重失败不影响发布。
+func (d *Downloader) linkBlobs(launcher, version, dir string, assets []ReleaseAssetSimple) int64 {
+	var saved int64
+	for _, a := range assets {
+		if a.SHA256 == "" {
+			continue
+		}
+		n, err := d.Blobs.Link(filepath.Join(dir, a.Name), a.SHA256, blobs.Ref{Launcher: launcher, Version: version, Name: a.Name})
+		if err != nil {
+			log.Printf("%s: 资源 %s 去重失败，保留独立文件: %v", launcher, a.Name, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("%s: 资源 %s 与已有文件内容相同，已链接到同一份数据（节省 %d 字节）", launcher, a.Name, n)
+		}
+		saved += n
+	}
+	return saved
+}
+
+// DedupePublished 将已发布版本中尚未纳入 blob 存储的资源纳入存储，用于启用去重之前镜像的文件。
+// index.json 中没有记录 sha256 的资源会重新计算。返回处理的文件数与节省的字节数。
+func DedupePublished(store *blobs.Store, destBase string) (int, int64, error) {
+	launchers, err := os.ReadDir(destBase)
+	if err != nil {
+		return 0, 0, err
+	}
+	files := 0
+	var saved int64
+	for _, l := range launchers {
+		if !l.IsDir() || IsHidden(l.Name()) {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(destBase, l.Name()))
+		if err != nil {
+			continue
+		}
+		for _, v := range versions {
+			if !v.IsDir() || IsHidden(v.Name()) {
+				continue
+			}
+			final := filepath.Join(destBase, l.Name(), v.Name())
+			info, err := readPublished(final)
+			if err != nil {
+				continue
+			}
+			for _, a := range info.Assets {
+				path := filepath.Join(final, a.Name)
+				ref := blobs.Ref{Launcher: l.Name(), Version: v.Name(), Name: a.Name}
+				if store.Linked(path, a.SHA256, ref) {
+					continue
+				}
+				sum := a.SHA256
+				if sum == "" {
+					if sum, err = fileSHA256(path); err != nil {
+						continue
+					}
+				}
+				n, err := store.Link(path, sum, ref)
+				if err != nil {
+					log.Printf("%s: 资源 %s/%s 去重失败: %v", l.Name(), v.Name(), a.Name, err)
+					continue
+				}
+				files++
+				saved += n
+			}
+		}
+	}
+	return files, saved, nil
+}
@@ -8,14 +8,16 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/go-github/v50/github"
+	"lemwood_mirror/internal/blobs"
+	"lemwood_mirror/internal/events"
+	"lemwood_mirror/internal/quota"
+	"lemwood_mirror/internal/retry"
 )
 
 type ReleaseInfo struct {
@@ -24,53 +26,169 @@ type ReleaseInfo struct {
 	Name        string               `json:"name"`
 	PublishedAt time.Time            `json:"published_at"`
 	IsLatest    bool                 `json:"is_latest"`
+	Prerelease  bool                 `json:"prerelease"`
+	Body        string               `json:"body"`     // 上游 release 说明（Markdown 原文）
+	Author      string               `json:"author"`   // 发布者的 GitHub 登录名
+	HTMLURL     string               `json:"html_url"` // 上游 release 页面地址
 	Assets      []ReleaseAssetSimple `json:"assets"`
 }
 
 type ReleaseAssetSimple struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
-	Size int    `json:"size"`
+	Name                  string    `json:"name"`
+	URL                   string    `json:"url"`
+	Size                  int       `json:"size"`
+	ContentType           string    `json:"content_type"`
+	UpdatedAt             time.Time `json:"updated_at"`
+	UpstreamURL           string    `json:"upstream_url"`
+	UpstreamDownloadCount int       `json:"upstream_download_count"` // 镜像时上游统计的下载次数
+	UpstreamID            int64     `json:"upstream_id,omitempty"`
+	UpstreamDigest        string    `json:"upstream_digest,omitempty"` // 上游提供的摘要，如 sha256:<hex>
+	SHA256                string    `json:"sha256,omitempty"`          // 镜像文件的 SHA-256
+	TorrentURL            string    `json:"torrent_url,omitempty"`     // v1 + v2 混合种子
+	MetalinkURL           string    `json:"metalink_url,omitempty"`    // Metalink 4 文件
+	InfoHash              string    `json:"btih,omitempty"`            // 种子的 v1 info hash
+	InfoHashV2            string    `json:"btmh,omitempty"`            // 种子的 v2 info hash
+	Magnet                string    `json:"magnet,omitempty"`
 }
 
+// Downloader 是进程内唯一的下载队列。任务持久化在 download_jobs 表中，
+// 按优先级调度并遵守全局与单个主机的并发上限，重启后继续未完成的任务。
 type Downloader struct {
 	httpClient *http.Client
-	semaphore  chan struct{}
+	// Mirrors 为上游下载策略集合；为 nil 时只直接下载
+	Mirrors *MirrorSet
+	// Limiter 为所有下载共享的带宽限速器，nil 表示不限速
+	Limiter *RateLimiter
+	// Schedule 限制大资源的下载时间窗口，nil 表示随时可以下载
+	Schedule *Schedule
+	// ProxyURL 为下载使用的 HTTP 代理，需要在 Start 之前设置
+	ProxyURL string
+	// Retry 为单个资源的重试策略，零值使用默认策略
+	Retry retry.Policy
+	// Blobs 为按内容寻址的资源存储，发布前相同内容的资源链接到同一个文件；nil 表示不去重
+	Blobs *blobs.Store
+	// Quota 在下载前检查可用空间与配额，nil 表示不检查
+	Quota *quota.Guard
+	// Trackers 写入生成的种子，为空时种子只依赖 DHT 与 web seed
+	Trackers []string
+
+	ctx         context.Context
+	mu          sync.Mutex
+	limit       int
+	perHost     int
+	running     int
+	hostRunning map[string]int
+	active      map[int64]bool // 正在下载的任务，数据库中的 running 状态可能因更新失败而残留
+	waiters     map[int64][]chan AssetResult
+	budgets     map[int64]*retry.Budget // 任务所属扫描的重试预算
+	wakeCh      chan struct{}
 }
 
-func NewDownloader(timeoutMinutes, concurrentDownloads int) *Downloader {
+// Result 是一次版本下载的结果
+type Result struct {
+	Version  string        `json:"version"`
+	InfoPath string        `json:"info_path"`
+	Assets   []AssetResult `json:"assets"`
+	// DedupedBytes 为与已有文件内容相同、通过链接节省的字节数
+	DedupedBytes int64 `json:"deduped_bytes,omitempty"`
+}
+
+// AssetResult 记录单个资源的下载结果，Strategy 为实际使用的上游下载策略
+type AssetResult struct {
+	Name     string        `json:"name"`
+	Strategy string        `json:"strategy,omitempty"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+	Skipped  bool          `json:"skipped"`
+	Deferred bool          `json:"deferred,omitempty"` // 不在下载时间窗口内，留待窗口开启后下载
+	Error    string        `json:"error,omitempty"`
+}
+
+// BytesFetched 返回本次实际下载的字节数
+func (r *Result) BytesFetched() int64 {
+	var n int64
+	for _, a := range r.Assets {
+		n += a.Bytes
+	}
+	return n
+}
+
+// NewDownloader 创建下载队列，concurrentDownloads 为全局并发上限，perHostDownloads 为单个主机的并发上限（<= 0 时不单独限制）
+func NewDownloader(timeoutMinutes, concurrentDownloads, perHostDownloads int) *Downloader {
 	if concurrentDownloads <= 0 {
 		concurrentDownloads = 3 // 如果无效，默认为 3
 	}
 	return &Downloader{
-		httpClient: &http.Client{Timeout: time.Duration(timeoutMinutes) * time.Minute},
-		semaphore:  make(chan struct{}, concurrentDownloads),
+		httpClient:  &http.Client{Timeout: time.Duration(timeoutMinutes) * time.Minute},
+		limit:       concurrentDownloads,
+		perHost:     perHostDownloads,
+		hostRunning: make(map[string]int),
+		active:      make(map[int64]bool),
+		waiters:     make(map[int64][]chan AssetResult),
+		budgets:     make(map[int64]*retry.Budget),
+		wakeCh:      make(chan struct{}, 1),
 	}
 }
 
-func (d *Downloader) DownloadLatest(ctx context.Context, launcher string, destBase string, proxyURL string, assetProxyURL string, xgetEnabled bool, xgetDomain string, rel *github.RepositoryRelease, serverAddress string, serverPort int, downloadUrlBase string, isLatest bool) (string, error) {
+// DownloadLatest 写入版本的 index.json，并把资源加入下载队列，等待全部资源下载完成。
+// 被推迟到下载时间窗口的资源不等待，此时返回 ErrDeferred。
+// isLatest 表示这是上游的最新版本，用于队列优先级与时间窗口豁免；latest 标记由调用方在发布后切换。
+// force 为 true 时不复用已下载的文件，全部资源重新下载并重新计算 SHA-256。
+func (d *Downloader) DownloadLatest(ctx context.Context, launcher string, destBase string, rel *Release, serverAddress string, serverPort int, downloadUrlBase string, isLatest bool, force bool) (*Result, error) {
 	if rel == nil {
-		return "", errors.New("release 为空")
+		return nil, errors.New("release 为空")
 	}
-	version := rel.GetTagName()
-	if version == "" {
-		version = rel.GetName()
-		if version == "" {
-			version = fmt.Sprintf("%d", rel.GetID())
+	version := rel.Version()
+	result := &Result{Version: version}
+	// 启动器名、版本号与资源名都会拼接到存储路径中，拒绝可能逃出存储目录的名称
+	if err := ValidName(launcher); err != nil {
+		return result, fmt.Errorf("启动器名无效: %w", err)
+	}
+	if err := ValidName(version); err != nil {
+		return result, fmt.Errorf("版本号无效: %w", err)
+	}
+	for _, a := range rel.Assets {
+		if err := ValidName(a.Name); err != nil {
+			return result, fmt.Errorf("资源名无效: %w", err)
 		}
 	}
-	dir := filepath.Join(destBase, launcher, version)
+	// 资源先下载到隐藏的暂存目录，校验完整后再整体移动到 <launcher>/<version>
+	final := filepath.Join(destBase, launcher, version)
+	dir := stagingDir(destBase, launcher, version)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", fmt.Errorf("创建目录 %s 失败: %w", dir, err)
+		return result, fmt.Errorf("创建目录 %s 失败: %w", dir, err)
+	}
+	// 上游重新上传过的资源（ID、更新时间或摘要变化）不能复用已发布的旧文件
+	prev, _ := readPublished(final)
+	changed := changedAssets(prev, rel.Assets)
+	assets := knownSizes(rel.Assets, prev, changed)
+	for name, reason := range changed {
+		log.Printf("%s: 资源 %s 已在上游被替换（%s），将重新下载", launcher, name, reason)
+		os.Remove(filepath.Join(dir, name))
+		os.Remove(filepath.Join(dir, name) + ".partial")
+	}
+	if force {
+		log.Printf("%s: 强制重新下载版本 %s 的全部资源", launcher, version)
+		for _, a := range assets {
+			os.Remove(filepath.Join(dir, a.Name))
+			os.Remove(filepath.Join(dir, a.Name) + ".partial")
+		}
+	} else {
+		seedStaging(final, dir, assets, changed)
 	}
 
 	var info ReleaseInfo
 	info.Launcher = launcher
-	info.TagName = rel.GetTagName()
-	info.Name = rel.GetName()
-	info.PublishedAt = rel.GetPublishedAt().Time
-	info.IsLatest = isLatest
-	for _, a := range rel.Assets {
+	info.TagName = rel.TagName
+	info.Name = rel.Name
+	info.PublishedAt = rel.PublishedAt
+	// latest 标记由服务端在版本发布后切换，这里只保留已发布版本原有的标记
+	info.IsLatest = publishedLatest(final)
+	info.Prerelease = rel.Prerelease
+	info.Body = rel.Body
+	info.Author = rel.Author
+	info.HTMLURL = rel.HTMLURL
+	for _, a := range assets {
 		var downloadURL string
 		if downloadUrlBase != "" {
 			// 如果提供了 downloadUrlBase，则直接使用它。
@@ -80,75 +198,192 @@ func (d *Downloader) DownloadLatest(ctx context.Context, launcher string, destBa
 				baseURL = "http://" + baseURL
 			}
 			baseURL = strings.TrimRight(baseURL, "/")
-			downloadURL = fmt.Sprintf("%s/download/%s/%s/%s", baseURL, launcher, version, a.GetName())
+			downloadURL = fmt.Sprintf("%s/download/%s/%s/%s", baseURL, launcher, version, a.Name)
 		} else if serverAddress != "" {
-			downloadURL = FormatDownloadURL(serverAddress, serverPort, "", launcher, version, a.GetName())
+			downloadURL = FormatDownloadURL(serverAddress, serverPort, "", launcher, version, a.Name)
 		} else {
 			publicIP, err := getPublicIP()
 			if err != nil {
-				log.Printf("无法获取公网 IP: %v。回退到资源 %s 的 GitHub URL", err, a.GetName())
-				downloadURL = a.GetBrowserDownloadURL()
+				log.Printf("无法获取公网 IP: %v。回退到资源 %s 的上游 URL", err, a.Name)
+				downloadURL = a.URL
 			} else {
-				downloadURL = FormatDownloadURL("", serverPort, publicIP, launcher, version, a.GetName())
+				downloadURL = FormatDownloadURL("", serverPort, publicIP, launcher, version, a.Name)
 			}
 		}
 		info.Assets = append(info.Assets, ReleaseAssetSimple{
-			Name: a.GetName(),
-			URL:  downloadURL,
-			Size: a.GetSize(),
+			Name:                  a.Name,
+			URL:                   downloadURL,
+			Size:                  a.Size,
+			ContentType:           a.ContentType,
+			UpdatedAt:             a.UpdatedAt,
+			UpstreamURL:           a.URL,
+			UpstreamDownloadCount: a.DownloadCount,
+			UpstreamID:            a.ID,
+			UpstreamDigest:        a.Digest,
 		})
 	}
 
-	indexPath := filepath.Join(dir, "index.json")
-	b, err := json.MarshalIndent(info, "", "  ")
-	if err != nil {
-		return "", fmt.Errorf("序列化 index.json 失败: %w", err)
-	}
-	if err := os.WriteFile(indexPath, b, 0o644); err != nil {
-		return "", fmt.Errorf("写入 index.json 失败: %w", err)
+	// 下载前确认剩余的资源能放得下，空间或配额不足时不开始下载
+	if d.Quota != nil {
+		release, err := d.Quota.Reserve(launcher, version, pendingBytes(dir, assets))
+		if err != nil {
+			return result, err
+		}
+		defer release()
 	}
-	log.Printf("已将版本信息写入 %s", indexPath)
 
-	client := d.httpClient
-	if proxyURL != "" {
-		proxy, err := url.Parse(proxyURL)
+	type pending struct {
+		name string
+		ch   chan AssetResult
+	}
+	var waits []pending
+	var firstErr error
+	deferred := 0
+	for _, asset := range assets {
+		if asset.URL != "" && assetComplete(filepath.Join(dir, asset.Name), asset.Size) {
+			log.Printf("文件 %s 已存在且大小一致，跳过下载。", asset.Name)
+			result.Assets = append(result.Assets, AssetResult{Name: asset.Name, Skipped: true})
+			continue
+		}
+		ch, isDeferred, err := d.enqueue(launcher, version, dir, asset, isLatest, retry.BudgetFrom(ctx))
 		if err != nil {
-			return "", fmt.Errorf("解析代理URL失败: %w", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
 		}
-		// 为代理创建新的客户端，因为默认客户端可能是共享的
-		client = &http.Client{
-			Timeout:   d.httpClient.Timeout,
-			Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+		if isDeferred {
+			log.Printf("资源 %s（%d 字节）不在下载时间窗口内，推迟到 %s", asset.Name, asset.Size, d.Schedule.NextOpen(time.Now()).Format("2006-01-02 15:04"))
+			result.Assets = append(result.Assets, AssetResult{Name: asset.Name, Deferred: true})
+			deferred++
+			continue
 		}
+		waits = append(waits, pending{asset.Name, ch})
 	}
 
-	var wg sync.WaitGroup
-	errCh := make(chan error, len(rel.Assets))
+	for _, w := range waits {
+		select {
+		case res := <-w.ch:
+			result.Assets = append(result.Assets, res)
+			if res.Error != "" && firstErr == nil {
+				firstErr = fmt.Errorf("下载资源 %s 失败: %s", res.Name, res.Error)
+			}
+		case <-ctx.Done():
+			// 任务仍在队列中继续，下次扫描时会重新等待
+			return result, fmt.Errorf("等待资源 %s 下载失败: %w", w.name, ctx.Err())
+		}
+	}
 
-	for _, asset := range rel.Assets {
-		wg.Add(1)
-		go func(asset *github.ReleaseAsset) {
-			defer wg.Done()
-			d.semaphore <- struct{}{}
-			defer func() { <-d.semaphore }()
+	if firstErr != nil {
+		return result, firstErr
+	}
+	if deferred > 0 {
+		return result, fmt.Errorf("%d 个%w", deferred, ErrDeferred)
+	}
 
-			err := d.downloadAsset(ctx, client, asset, dir, assetProxyURL, xgetEnabled, xgetDomain)
-			if err != nil {
-				errCh <- err
+	// 所有资源齐全后才写入 index.json 并发布，未完成的版本不会出现在下载目录中
+	var expected []ReleaseAssetSimple
+	for i := range info.Assets {
+		a := &info.Assets[i]
+		if a.UpstreamURL == "" {
+			continue
+		}
+		// 上游未提供大小时记录实际下载的大小，之后的扫描据此判断文件是否完整
+		if a.Size <= 0 {
+			if fi, err := os.Stat(filepath.Join(dir, a.Name)); err == nil {
+				a.Size = int(fi.Size())
+			}
+		}
+		expected = append(expected, *a)
+	}
+	if err := verifyDir(dir, expected); err != nil {
+		return result, fmt.Errorf("版本 %s 校验失败: %w", version, err)
+	}
+	if err := hashAssets(dir, info.Assets, prev, changed, force); err != nil {
+		return result, fmt.Errorf("版本 %s 校验失败: %w", version, err)
+	}
+	if force {
+		// 重新下载的内容与已发布的文件不同时，同样视为上游替换了资源
+		for name, reason := range contentChanged(prev, info.Assets) {
+			if _, ok := changed[name]; !ok {
+				changed[name] = reason
 			}
-		}(asset)
+		}
+	}
+	if d.Blobs != nil {
+		result.DedupedBytes = d.linkBlobs(launcher, version, dir, info.Assets)
+	}
+	d.writeSidecars(dir, final, info.Assets, prev)
+	if err := writeJSONAtomic(filepath.Join(dir, "index.json"), info); err != nil {
+		return result, fmt.Errorf("写入 index.json 失败: %w", err)
 	}
+	// 发布前为被替换的旧资源保留一份带日期的备份
+	now := time.Now()
+	backups := make(map[string]string, len(changed))
+	for name := range changed {
+		path, err := backupAsset(destBase, launcher, version, final, name, now)
+		if err != nil {
+			log.Printf("%s: 备份旧资源 %s 失败: %v", launcher, name, err)
+			continue
+		}
+		backups[name] = path
+	}
+	if err := publishDir(dir, final); err != nil {
+		return result, fmt.Errorf("发布版本 %s 失败: %w", version, err)
+	}
+	result.InfoPath = filepath.Join(final, "index.json")
+	log.Printf("已发布版本 %s 到 %s", version, final)
+	for name, reason := range changed {
+		msg := fmt.Sprintf("版本 %s 的资源 %s 已在上游被替换并重新镜像：%s", version, name, reason)
+		if path, ok := backups[name]; ok {
+			msg += "，旧文件备份于 " + path
+		}
+		events.Alert(events.KindAssetChanged, launcher, "%s", msg)
+	}
+	return result, nil
+}
 
-	wg.Wait()
-	close(errCh)
+// publishedLatest 返回已发布版本目录中 index.json 的 is_latest 标记
+func publishedLatest(final string) bool {
+	b, err := os.ReadFile(filepath.Join(final, "index.json"))
+	if err != nil {
+		return false
+	}
+	var info ReleaseInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return false
+	}
+	return info.IsLatest
+}
 
-	for err := range errCh {
-		if err != nil {
-			return "", err
+// pendingBytes 返回暂存目录中尚未下载完成的资源还需下载的字节数，.partial 文件已下载的部分不计入。
+// 大小未知的资源无法预估，按 0 计算。
+func pendingBytes(dir string, assets []Asset) int64 {
+	var need int64
+	for _, a := range assets {
+		path := filepath.Join(dir, a.Name)
+		if a.URL == "" || assetComplete(path, a.Size) {
+			continue
 		}
+		n := int64(a.Size)
+		if fi, err := os.Stat(path + ".partial"); err == nil && fi.Size() < n {
+			n -= fi.Size()
+		}
+		need += n
 	}
+	return need
+}
 
-	return indexPath, nil
+// assetComplete 判断本地文件是否已存在且大小与上游一致。上游未提供大小时只要求文件非空，内容由摘要校验。
+func assetComplete(path string, size int) bool {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if size <= 0 {
+		return fi.Size() > 0
+	}
+	return fi.Size() == int64(size)
 }
 
 // 缓存公网 IP，避免重复请求
@@ -201,88 +436,166 @@ func getPublicIP() (string, error) {
 	return result, nil
 }
 
-func (d *Downloader) downloadAsset(ctx context.Context, client *http.Client, asset *github.ReleaseAsset, dir, assetProxyURL string, xgetEnabled bool, xgetDomain string) error {
-	name := asset.GetName()
+func (d *Downloader) downloadAsset(ctx context.Context, client *http.Client, asset Asset, dir string, mirrors *MirrorSet) AssetResult {
+	name := asset.Name
 	outfile := filepath.Join(dir, name)
+	res := AssetResult{Name: name}
 
 	if fileInfo, err := os.Stat(outfile); err == nil {
-		if fileInfo.Size() == int64(asset.GetSize()) {
+		if assetComplete(outfile, asset.Size) {
 			log.Printf("文件 %s 已存在且大小一致，跳过下载。", name)
-			return nil
+			res.Skipped = true
+			return res
 		}
-		log.Printf("文件 %s 已存在但大小不一致 (本地: %d, 远程: %d)，将重新下载。", name, fileInfo.Size(), asset.GetSize())
+		log.Printf("文件 %s 已存在但大小不一致 (本地: %d, 远程: %d)，将重新下载。", name, fileInfo.Size(), asset.Size)
 	}
 
-	downloadURL := asset.GetBrowserDownloadURL()
-	if downloadURL != "" && assetProxyURL != "" {
-		downloadURL = assetProxyURL + downloadURL
-	}
-	if downloadURL != "" && xgetEnabled && strings.HasPrefix(downloadURL, "https://github.com/") {
-		downloadURL = strings.Replace(downloadURL, "https://github.com/", xgetDomain+"/gh/", 1)
-	}
-	if downloadURL == "" {
+	if asset.URL == "" {
 		log.Printf("资源 %s 没有下载链接，跳过", name)
-		return nil
+		res.Skipped = true
+		return res
 	}
 	if name == "" {
-		name = filepath.Base(downloadURL)
+		name = filepath.Base(asset.URL)
 	}
-	log.Printf("开始下载 %s 到 %s", downloadURL, outfile)
 
-	partial := outfile + ".partial"
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	// 每次尝试按评分依次使用各个下载策略；全部失败时按重试策略退避后再试，
+	// 所有策略都返回永久错误（如 404）时不再重试
+	err := d.Retry.Do(ctx, func(attempt int) error {
+		if attempt > 1 {
+			log.Printf("下载 %s 的所有策略均失败，第 %d 次尝试...", name, attempt)
+		}
+		var lastErr error
+		permanent := true
+		for _, st := range mirrors.Ordered(asset.URL) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			target, _ := st.Rewrite(asset.URL)
+			log.Printf("开始通过 %s 下载 %s 到 %s", st.Name, target, outfile)
+			start := time.Now()
+			n, err := fetchToFile(ctx, client, d.Limiter, target, outfile, name)
+			if err == nil {
+				// 镜像返回的内容可能被截断或替换，与上游的大小和摘要核对，不一致时换下一个策略
+				if err = verifyFetched(outfile, asset); err != nil {
+					os.Remove(outfile)
+				}
+			}
+			elapsed := time.Since(start)
+			st.Report(err == nil, n, elapsed, err)
+			if err == nil {
+				res.Strategy = st.Name
+				res.Bytes = n
+				res.Duration = elapsed
+				log.Printf("完成下载 %s（策略: %s）", outfile, st.Name)
+				return nil
+			}
+			res.Bytes += n
+			lastErr = err
+			if !retry.IsPermanent(err) {
+				permanent = false
+			}
+			log.Printf("通过 %s 下载 %s 失败: %v", st.Name, name, err)
+		}
+		if lastErr == nil {
+			return retry.Permanent(errors.New("没有可用的下载策略"))
+		}
+		if permanent {
+			return retry.Permanent(lastErr)
+		}
+		return lastErr
+	})
 	if err != nil {
-		return err
+		res.Error = err.Error()
 	}
+	return res
+}
 
-	var resp *http.Response
-	for i := 0; i < 3; i++ {
-		resp, err = client.Do(req)
-		if err == nil && resp.StatusCode == http.StatusOK {
-			break
-		}
-		if resp != nil {
-			resp.Body.Close()
+// verifyFetched 检查下载的文件与上游记录的大小一致，上游提供 SHA-256 摘要时同时核对摘要
+func verifyFetched(path string, asset Asset) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if asset.Size > 0 && fi.Size() != int64(asset.Size) {
+		return fmt.Errorf("资源 %s 的大小与上游不一致 (下载: %d, 上游: %d)", asset.Name, fi.Size(), asset.Size)
+	}
+	if algo, _, ok := strings.Cut(asset.Digest, ":"); ok && strings.EqualFold(algo, "sha256") {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
 		}
-		log.Printf("下载 %s 失败，5秒后重试...", downloadURL)
-		time.Sleep(5 * time.Second)
+		return checkDigest(asset.Name, sum, asset.Digest)
+	}
+	return nil
+}
+
+// fetchToFile 将 downloadURL 下载到 outfile（先写入 .partial 再重命名），返回本次写入的字节数。
+// 已有 .partial 文件时通过 Range 请求断点续传；limiter 非空时读取速度受全局带宽限制。
+func fetchToFile(ctx context.Context, client *http.Client, limiter *RateLimiter, downloadURL, outfile, name string) (int64, error) {
+	partial := outfile + ".partial"
+	var offset int64
+	if fi, err := os.Stat(partial); err == nil {
+		offset = fi.Size()
 	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("下载资源 %s 失败，状态码: %d", downloadURL, resp.StatusCode)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		flags = os.O_WRONLY | os.O_APPEND
+		log.Printf("从 %d 字节处继续下载 %s", offset, name)
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 本地的 .partial 已不可续传，删除后从头下载
+		resp.Body.Close()
+		os.Remove(partial)
+		return fetchToFile(ctx, client, limiter, downloadURL, outfile, name)
+	default:
+		return 0, retry.FromResponse(resp, fmt.Errorf("下载资源 %s 失败，状态码: %d", downloadURL, resp.StatusCode))
 	}
 
-	f, err := os.Create(partial)
+	f, err := os.OpenFile(partial, flags, 0o644)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer func() {
-		f.Close()
-		os.Remove(partial)
-	}()
+	// 失败时保留 .partial 文件，供下次断点续传
+	defer f.Close()
 
+	total := resp.ContentLength
+	if total >= 0 {
+		total += offset
+	}
 	progressWriter := &progressWriter{
-		total:      resp.ContentLength,
+		total:      total,
+		written:    offset,
 		fileName:   name,
 		lastUpdate: time.Now(),
 	}
-	if _, err := io.Copy(f, io.TeeReader(resp.Body, progressWriter)); err != nil {
-		return err
+	n, err := io.Copy(f, io.TeeReader(limiter.Reader(ctx, resp.Body), progressWriter))
+	if err != nil {
+		return n, err
 	}
 
 	if err := f.Close(); err != nil {
-		return err
+		return n, err
 	}
 	if err := os.Rename(partial, outfile); err != nil {
-		return err
+		return n, err
 	}
-
-	log.Printf("完成下载 %s", outfile)
-	return nil
+	return n, nil
 }
 
 type progressWriter struct {
@@ -0,0 +1,10 @@
+//go:build linux
+
+package downloader
+
+import "golang.org/x/sys/unix"
+
+// exchangeDirs 使用 renameat2(RENAME_EXCHANGE) 原子地交换两个目录
+func exchangeDirs(a, b string) error {
+	return unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package downloader
+
+import "errors"
+
+// exchangeDirs 在不支持原子交换的系统上总是失败，由调用方退回到两次重命名
+func exchangeDirs(a, b string) error {
+	return errors.New("不支持原子交换目录")
+}
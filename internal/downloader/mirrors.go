@@ -0,0 +1,275 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 上游下载策略类型
+const (
+	StrategyDirect = "direct" // 直接从 GitHub 下载
+	StrategyProxy  = "proxy"  // 在下载地址前拼接代理前缀
+	StrategyXget   = "xget"   // 将 https://github.com/ 替换为 <xget 域名>/gh/
+)
+
+const githubPrefix = "https://github.com/"
+
+// ewmaAlpha 为成功率与吞吐量的指数加权平均系数，越大越偏向最近的结果
+const ewmaAlpha = 0.3
+
+// MirrorSpec 描述一个上游下载策略
+type MirrorSpec struct {
+	Name string
+	Type string
+	URL  string // proxy 的前缀或 xget 的域名
+}
+
+// Strategy 是一个带健康评分的上游下载策略
+type Strategy struct {
+	Name string
+	Type string
+	Base string
+
+	mu          sync.Mutex
+	successRate float64 // 最近成功率的指数加权平均，初始为 1
+	throughput  float64 // 最近吞吐量（字节/秒）的指数加权平均
+	healthy     bool
+	uses        int64
+	failures    int64
+	lastError   string
+	lastCheck   time.Time
+}
+
+// StrategyStatus 是策略健康状况的快照
+type StrategyStatus struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Healthy     bool      `json:"healthy"`
+	Score       float64   `json:"score"`
+	SuccessRate float64   `json:"success_rate"`
+	Throughput  float64   `json:"throughput"`
+	Uses        int64     `json:"uses"`
+	Failures    int64     `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastCheck   time.Time `json:"last_check"`
+}
+
+// Rewrite 返回通过该策略下载 upstream 时使用的地址。代理与 Xget 只对 GitHub 上的资源生效。
+func (s *Strategy) Rewrite(upstream string) (string, bool) {
+	switch s.Type {
+	case StrategyDirect:
+		return upstream, true
+	case StrategyProxy:
+		if !strings.HasPrefix(upstream, githubPrefix) {
+			return "", false
+		}
+		return s.Base + upstream, true
+	case StrategyXget:
+		if !strings.HasPrefix(upstream, githubPrefix) {
+			return "", false
+		}
+		return strings.TrimRight(s.Base, "/") + "/gh/" + strings.TrimPrefix(upstream, githubPrefix), true
+	}
+	return "", false
+}
+
+// Report 记录一次下载结果，用于更新评分
+func (s *Strategy) Report(ok bool, bytes int64, dur time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uses++
+	result := 0.0
+	if ok {
+		result = 1
+		s.healthy = true
+		if dur > 0 && bytes > 0 {
+			tp := float64(bytes) / dur.Seconds()
+			if s.throughput == 0 {
+				s.throughput = tp
+			} else {
+				s.throughput = ewmaAlpha*tp + (1-ewmaAlpha)*s.throughput
+			}
+		}
+	} else {
+		s.failures++
+		if err != nil {
+			s.lastError = err.Error()
+		}
+	}
+	s.successRate = ewmaAlpha*result + (1-ewmaAlpha)*s.successRate
+}
+
+// score 综合成功率与吞吐量计算评分，健康检查失败的策略大幅降权
+func (s *Strategy) score() float64 {
+	score := s.successRate * (1 + math.Log1p(s.throughput/(1<<20)))
+	if !s.healthy {
+		score *= 0.1
+	}
+	return score
+}
+
+func (s *Strategy) status() StrategyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return StrategyStatus{
+		Name:        s.Name,
+		Type:        s.Type,
+		Healthy:     s.healthy,
+		Score:       s.score(),
+		SuccessRate: s.successRate,
+		Throughput:  s.throughput,
+		Uses:        s.uses,
+		Failures:    s.failures,
+		LastError:   s.lastError,
+		LastCheck:   s.lastCheck,
+	}
+}
+
+// MirrorSet 是按配置顺序排列的一组上游下载策略
+type MirrorSet struct {
+	strategies []*Strategy
+}
+
+// NewMirrorSet 根据配置创建策略集合，未知类型会被忽略。集合为空时退化为直接下载。
+func NewMirrorSet(specs []MirrorSpec) *MirrorSet {
+	m := &MirrorSet{}
+	for _, spec := range specs {
+		t := strings.ToLower(spec.Type)
+		if t != StrategyDirect && t != StrategyProxy && t != StrategyXget {
+			log.Printf("忽略未知的下载策略类型 %q", spec.Type)
+			continue
+		}
+		if t != StrategyDirect && spec.URL == "" {
+			log.Printf("下载策略 %q 缺少 url，已忽略", spec.Name)
+			continue
+		}
+		name := spec.Name
+		if name == "" {
+			name = t
+			if spec.URL != "" {
+				name = t + ":" + spec.URL
+			}
+		}
+		m.strategies = append(m.strategies, &Strategy{Name: name, Type: t, Base: spec.URL, successRate: 1, healthy: true})
+	}
+	if len(m.strategies) == 0 {
+		m.strategies = append(m.strategies, &Strategy{Name: StrategyDirect, Type: StrategyDirect, successRate: 1, healthy: true})
+	}
+	return m
+}
+
+// LegacyMirrorSpecs 将旧的 asset_proxy_url 与 xget 配置转换为策略列表：Xget、代理前缀、直连依次尝试
+func LegacyMirrorSpecs(assetProxyURL string, xgetEnabled bool, xgetDomain string) []MirrorSpec {
+	var specs []MirrorSpec
+	if xgetEnabled && xgetDomain != "" {
+		specs = append(specs, MirrorSpec{Type: StrategyXget, URL: xgetDomain})
+	}
+	if assetProxyURL != "" {
+		specs = append(specs, MirrorSpec{Type: StrategyProxy, URL: assetProxyURL})
+	}
+	return append(specs, MirrorSpec{Type: StrategyDirect})
+}
+
+// Ordered 返回适用于 upstream 的策略，按评分从高到低排列，评分相同时保持配置顺序
+func (m *MirrorSet) Ordered(upstream string) []*Strategy {
+	type scored struct {
+		s     *Strategy
+		score float64
+	}
+	var list []scored
+	for _, s := range m.strategies {
+		if _, ok := s.Rewrite(upstream); !ok {
+			continue
+		}
+		s.mu.Lock()
+		list = append(list, scored{s, s.score()})
+		s.mu.Unlock()
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].score > list[j].score })
+	out := make([]*Strategy, len(list))
+	for i, sc := range list {
+		out[i] = sc.s
+	}
+	return out
+}
+
+// Status 返回所有策略的健康状况
+func (m *MirrorSet) Status() []StrategyStatus {
+	out := make([]StrategyStatus, 0, len(m.strategies))
+	for _, s := range m.strategies {
+		out = append(out, s.status())
+	}
+	return out
+}
+
+// HealthCheck 通过每个策略请求 probeURL，能得到非 5xx 响应即视为健康
+func (m *MirrorSet) HealthCheck(ctx context.Context, client *http.Client, probeURL string) {
+	var wg sync.WaitGroup
+	for _, s := range m.strategies {
+		target, ok := s.Rewrite(probeURL)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(s *Strategy, target string) {
+			defer wg.Done()
+			err := probe(ctx, client, target)
+			s.mu.Lock()
+			s.lastCheck = time.Now()
+			s.healthy = err == nil
+			if err != nil {
+				s.lastError = err.Error()
+			}
+			s.mu.Unlock()
+			if err != nil {
+				log.Printf("下载策略 %s 健康检查失败: %v", s.Name, err)
+			}
+		}(s, target)
+	}
+	wg.Wait()
+}
+
+func probe(ctx context.Context, client *http.Client, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// StartHealthChecks 在后台按 interval 定期执行健康检查，proxyURL 非空时通过代理访问
+func (m *MirrorSet) StartHealthChecks(interval time.Duration, probeURL, proxyURL string) {
+	if interval <= 0 || probeURL == "" {
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if proxyURL != "" {
+		if proxy, err := url.Parse(proxyURL); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+		}
+	}
+	go func() {
+		for {
+			m.HealthCheck(context.Background(), client, probeURL)
+			time.Sleep(interval)
+		}
+	}()
+}
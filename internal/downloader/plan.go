@@ -0,0 +1,71 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Plan 是试运行得到的下载计划：DownloadLatest 将要下载的资源与将被替换的已发布文件
+type Plan struct {
+	Version    string         `json:"version"`
+	Fetch      []PlannedAsset `json:"fetch"`
+	FetchBytes int64          `json:"fetch_bytes"`
+	Reuse      []string       `json:"reuse"`   // 已下载、无需重新下载的资源
+	Replace    []string       `json:"replace"` // 将被替换的已发布文件，相对于存储根目录
+}
+
+// PlannedAsset 是计划下载的资源，Reason 说明需要下载的原因
+type PlannedAsset struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// PlanDownload 按 DownloadLatest 的规则计算下载计划，只读取磁盘，不做任何修改
+func PlanDownload(destBase, launcher string, rel *Release, force bool) *Plan {
+	version := rel.Version()
+	final := filepath.Join(destBase, launcher, version)
+	dir := stagingDir(destBase, launcher, version)
+	prev, _ := readPublished(final)
+	changed := changedAssets(prev, rel.Assets)
+
+	p := &Plan{Version: version, Fetch: []PlannedAsset{}, Reuse: []string{}, Replace: []string{}}
+	for _, a := range knownSizes(rel.Assets, prev, changed) {
+		if a.URL == "" {
+			continue
+		}
+		published := filepath.Join(final, a.Name)
+		var reason string
+		switch {
+		case changed[a.Name] != "":
+			reason = changed[a.Name]
+		case force:
+			reason = "强制重新下载"
+		case assetComplete(published, a.Size) || assetComplete(filepath.Join(dir, a.Name), a.Size):
+			p.Reuse = append(p.Reuse, a.Name)
+			continue
+		case fileExists(published):
+			reason = "已发布的文件大小不一致"
+		default:
+			reason = "新资源"
+		}
+		need := int64(a.Size)
+		// 未被替换的资源可以从暂存目录中的 .partial 文件断点续传
+		if changed[a.Name] == "" && !force {
+			if fi, err := os.Stat(filepath.Join(dir, a.Name) + ".partial"); err == nil && fi.Size() < need {
+				need -= fi.Size()
+			}
+		}
+		p.Fetch = append(p.Fetch, PlannedAsset{Name: a.Name, Size: a.Size, Reason: reason})
+		p.FetchBytes += need
+		if fileExists(published) {
+			p.Replace = append(p.Replace, filepath.ToSlash(filepath.Join(launcher, version, a.Name)))
+		}
+	}
+	return p
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StagingName 是每个启动器目录下存放未完成版本的隐藏目录名
+const StagingName = ".staging"
+
+// stagingDir 返回版本的暂存目录：<base>/<launcher>/.staging/<version>
+func stagingDir(destBase, launcher, version string) string {
+	return filepath.Join(destBase, launcher, StagingName, version)
+}
+
+// IsHidden 判断路径中是否包含以 "." 开头的隐藏目录或文件（暂存目录、.partial 等）
+func IsHidden(relPath string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidName 检查用作目录名或文件名的启动器名、版本号与资源名：不能为空，不能以 "." 开头，
+// 不能包含路径分隔符或 ".."，避免来自上游的名称写到存储目录之外或被当作隐藏文件
+func ValidName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("名称为空")
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("名称 %q 不能以 . 开头", name)
+	case strings.ContainsAny(name, "/\\\x00"), strings.Contains(name, ".."):
+		return fmt.Errorf("名称 %q 包含非法字符", name)
+	}
+	return nil
+}
+
+// seedStaging 将已发布版本中完整的资源硬链接到暂存目录，避免重新下载；skip 中的资源已在上游被替换，不复用
+func seedStaging(final, staging string, assets []Asset, skip map[string]string) {
+	if _, err := os.Stat(final); err != nil {
+		return
+	}
+	for _, a := range assets {
+		if _, ok := skip[a.Name]; ok {
+			continue
+		}
+		src := filepath.Join(final, a.Name)
+		dst := filepath.Join(staging, a.Name)
+		if !assetComplete(src, a.Size) || assetComplete(dst, a.Size) {
+			continue
+		}
+		os.Remove(dst)
+		if err := os.Link(src, dst); err != nil {
+			log.Printf("复用已发布的资源 %s 失败，将重新下载: %v", a.Name, err)
+		}
+	}
+}
+
+// verifyDir 检查 dir 中的资源是否齐全且大小与上游一致。上游未提供大小时只要求文件非空。
+func verifyDir(dir string, assets []ReleaseAssetSimple) error {
+	for _, a := range assets {
+		fi, err := os.Stat(filepath.Join(dir, a.Name))
+		if err != nil {
+			return fmt.Errorf("资源 %s 缺失", a.Name)
+		}
+		if a.Size > 0 && fi.Size() != int64(a.Size) {
+			return fmt.Errorf("资源 %s 大小不一致 (本地: %d, 远程: %d)", a.Name, fi.Size(), a.Size)
+		}
+		if a.Size <= 0 && fi.Size() == 0 {
+			return fmt.Errorf("资源 %s 为空", a.Name)
+		}
+	}
+	return nil
+}
+
+// VerifyVersionDir 读取 dir 下的 index.json，检查其中列出的资源是否齐全
+func VerifyVersionDir(dir string) error {
+	b, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return err
+	}
+	var info ReleaseInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return fmt.Errorf("解析 index.json 失败: %w", err)
+	}
+	return verifyDir(dir, info.Assets)
+}
+
+// writeJSONAtomic 先写入临时文件再重命名，读者不会看到写了一半的文件
+func writeJSONAtomic(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// publishDir 将暂存目录原子地移动到 final。final 已存在时优先原子交换两个目录，
+// 系统不支持时先把旧目录移开，失败则恢复旧目录。
+func publishDir(staging, final string) error {
+	if _, err := os.Stat(final); os.IsNotExist(err) {
+		return os.Rename(staging, final)
+	}
+	if err := exchangeDirs(staging, final); err == nil {
+		// 交换后 staging 中是旧版本的内容
+		if err := os.RemoveAll(staging); err != nil {
+			log.Printf("清理旧目录 %s 失败: %v", staging, err)
+		}
+		return nil
+	}
+	old := fmt.Sprintf("%s.old-%d", staging, time.Now().UnixNano())
+	if err := os.Rename(final, old); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, final); err != nil {
+		if rerr := os.Rename(old, final); rerr != nil {
+			log.Printf("恢复旧目录 %s 失败: %v", final, rerr)
+		}
+		return err
+	}
+	if err := os.RemoveAll(old); err != nil {
+		log.Printf("清理旧目录 %s 失败: %v", old, err)
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package downloader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"lemwood_mirror/internal/db"
+	"lemwood_mirror/internal/retry"
+)
+
+// 下载任务状态
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// doneJobRetention 为已完成任务记录的保留时间
+const doneJobRetention = 7 * 24 * time.Hour
+
+// Job 是持久化在 download_jobs 表中的一个资源下载任务
+type Job struct {
+	ID        int64     `json:"id"`
+	Launcher  string    `json:"launcher"`
+	Version   string    `json:"version"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Dir       string    `json:"-"`
+	Host      string    `json:"host"`
+	Size      int64     `json:"size"`
+	Digest    string    `json:"digest,omitempty"`
+	IsLatest  bool      `json:"is_latest"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Strategy  string    `json:"strategy,omitempty"`
+	Bytes     int64     `json:"bytes"`
+	Error     string    `json:"error,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Start 恢复上次中断的任务并启动调度循环。
+// 重启前处于 running 状态的任务会重新排队，未完成的 .partial 文件会断点续传。
+func (d *Downloader) Start(ctx context.Context) error {
+	if db.DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if d.ProxyURL != "" {
+		proxy, err := url.Parse(d.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("解析代理URL失败: %w", err)
+		}
+		d.httpClient = &http.Client{
+			Timeout:   d.httpClient.Timeout,
+			Transport: &http.Transport{Proxy: http.ProxyURL(proxy)},
+		}
+	}
+	if d.Mirrors == nil {
+		d.Mirrors = NewMirrorSet(nil)
+	}
+	res, err := db.DB.Exec(`UPDATE download_jobs SET status = ?, updated_at = ? WHERE status = ?`, JobPending, time.Now().Unix(), JobRunning)
+	if err != nil {
+		return fmt.Errorf("恢复下载任务失败: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("恢复了 %d 个中断的下载任务", n)
+	}
+	if _, err := db.DB.Exec(`DELETE FROM download_jobs WHERE status = ? AND updated_at < ?`, JobDone, time.Now().Add(-doneJobRetention).Unix()); err != nil {
+		log.Printf("清理已完成的下载任务失败: %v", err)
+	}
+	d.ctx = ctx
+	go d.dispatchLoop()
+	return nil
+}
+
+// enqueue 将资源加入队列并登记等待者。已在排队或下载中的任务保持原状，失败或已完成的任务重新排队。
+// 资源不在下载时间窗口内时任务推迟到窗口开启，deferred 为 true 且不登记等待者。
+// budget 为发起扫描的重试预算，任务重试时从中扣除。
+func (d *Downloader) enqueue(launcher, version, dir string, a Asset, isLatest bool, budget *retry.Budget) (wait chan AssetResult, deferred bool, err error) {
+	now := time.Now()
+	notBefore := now
+	if !d.Schedule.Allowed(int64(a.Size), isLatest, now) {
+		notBefore = d.Schedule.NextOpen(now)
+		deferred = true
+	}
+	host := ""
+	if u, err := url.Parse(a.URL); err == nil {
+		host = u.Host
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err = db.DB.Exec(`INSERT INTO download_jobs (launcher, version, name, url, dir, host, size, digest, is_latest, status, attempts, not_before, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?)
+        ON CONFLICT(launcher, version, name) DO UPDATE SET
+            url = excluded.url, dir = excluded.dir, host = excluded.host, size = excluded.size, digest = excluded.digest,
+            is_latest = MAX(download_jobs.is_latest, excluded.is_latest),
+            not_before = CASE WHEN download_jobs.status IN ('pending', 'running') THEN MIN(download_jobs.not_before, excluded.not_before) ELSE excluded.not_before END,
+            status = CASE WHEN download_jobs.status = 'running' THEN 'running' ELSE 'pending' END,
+            error = CASE WHEN download_jobs.status = 'running' THEN download_jobs.error ELSE '' END,
+            updated_at = excluded.updated_at`,
+		launcher, version, a.Name, a.URL, dir, host, a.Size, a.Digest, isLatest, JobPending, notBefore.Unix(), now.Unix(), now.Unix())
+	if err != nil {
+		return nil, false, fmt.Errorf("加入下载队列失败: %w", err)
+	}
+	var id int64
+	var status string
+	if err := db.DB.QueryRow(`SELECT id, status FROM download_jobs WHERE launcher = ? AND version = ? AND name = ?`, launcher, version, a.Name).Scan(&id, &status); err != nil {
+		return nil, false, fmt.Errorf("读取下载任务失败: %w", err)
+	}
+	if status == JobRunning && !d.active[id] {
+		// 任务结束时更新状态失败，数据库中残留 running 但已没有下载在进行，重新排队，避免等待者永远等不到结果
+		if err := execRetry(`UPDATE download_jobs SET status = ?, not_before = ?, updated_at = ? WHERE id = ?`, JobPending, notBefore.Unix(), now.Unix(), id); err != nil {
+			return nil, false, fmt.Errorf("重新排队下载任务 %s 失败: %w", a.Name, err)
+		}
+		status = JobPending
+	}
+	if status == JobRunning {
+		deferred = false
+	}
+	d.wake()
+	if deferred {
+		return nil, true, nil
+	}
+	wait = make(chan AssetResult, 1)
+	d.waiters[id] = append(d.waiters[id], wait)
+	if budget != nil {
+		d.budgets[id] = budget
+	}
+	return wait, false, nil
+}
+
+func (d *Downloader) wake() {
+	select {
+	case d.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchLoop 按优先级取出到期的任务：最新版本优先于历史版本，小文件优先于大文件，
+// 同时遵守全局与单个主机的并发上限
+func (d *Downloader) dispatchLoop() {
+	for {
+		next := d.dispatch()
+		wait := 30 * time.Second
+		if !next.IsZero() {
+			if until := time.Until(next); until < wait {
+				wait = until
+			}
+		}
+		if wait < time.Second {
+			wait = time.Second
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-d.ctx.Done():
+			t.Stop()
+			return
+		case <-d.wakeCh:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+// dispatch 启动尽可能多的任务，返回最近一个尚未到期任务的时间
+func (d *Downloader) dispatch() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running >= d.limit {
+		return time.Time{}
+	}
+	now := time.Now()
+	rows, err := db.DB.Query(`SELECT `+jobColumns+` FROM download_jobs WHERE status = ? AND not_before <= ?
+        ORDER BY is_latest DESC, size ASC, id ASC`, JobPending, now.Unix())
+	if err != nil {
+		log.Printf("读取下载队列失败: %v", err)
+		return time.Time{}
+	}
+	var ready []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			log.Printf("读取下载任务失败: %v", err)
+			continue
+		}
+		ready = append(ready, j)
+	}
+	rows.Close()
+
+	for _, j := range ready {
+		if d.running >= d.limit {
+			break
+		}
+		if d.perHost > 0 && d.hostRunning[j.Host] >= d.perHost {
+			continue
+		}
+		// 排队期间窗口可能已经关闭
+		if !d.Schedule.Allowed(j.Size, j.IsLatest, now) {
+			if _, err := db.DB.Exec(`UPDATE download_jobs SET not_before = ? WHERE id = ?`, d.Schedule.NextOpen(now).Unix(), j.ID); err != nil {
+				log.Printf("推迟下载任务 %s 失败: %v", j.Name, err)
+			}
+			continue
+		}
+		if err := execRetry(`UPDATE download_jobs SET status = ?, attempts = attempts + 1, updated_at = ? WHERE id = ?`, JobRunning, now.Unix(), j.ID); err != nil {
+			log.Printf("更新下载任务 %s 失败: %v", j.Name, err)
+			continue
+		}
+		d.active[j.ID] = true
+		d.running++
+		d.hostRunning[j.Host]++
+		go d.runJob(j)
+	}
+
+	var next sql.NullInt64
+	if err := db.DB.QueryRow(`SELECT MIN(not_before) FROM download_jobs WHERE status = ? AND not_before > ?`, JobPending, now.Unix()).Scan(&next); err == nil && next.Valid {
+		return time.Unix(next.Int64, 0)
+	}
+	return time.Time{}
+}
+
+func (d *Downloader) runJob(j Job) {
+	d.mu.Lock()
+	budget := d.budgets[j.ID]
+	d.mu.Unlock()
+	ctx := retry.WithBudget(d.ctx, budget)
+	if d.httpClient.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.httpClient.Timeout)
+		defer cancel()
+	}
+	asset := Asset{Name: j.Name, URL: j.URL, Size: int(j.Size), Digest: j.Digest}
+	res := d.downloadAsset(ctx, d.httpClient, asset, j.Dir, d.Mirrors)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.active, j.ID)
+	d.running--
+	d.hostRunning[j.Host]--
+	if d.hostRunning[j.Host] <= 0 {
+		delete(d.hostRunning, j.Host)
+	}
+	status := JobDone
+	if res.Error != "" {
+		status = JobFailed
+	}
+	if d.ctx.Err() != nil {
+		// 进程退出导致的中断在下次启动时继续
+		status = JobPending
+	}
+	// 更新失败时数据库中残留 running，再次加入队列时会因为 active 中没有该任务而重新排队
+	if err := execRetry(`UPDATE download_jobs SET status = ?, strategy = ?, bytes = ?, error = ?, updated_at = ? WHERE id = ?`,
+		status, res.Strategy, res.Bytes, res.Error, time.Now().Unix(), j.ID); err != nil {
+		log.Printf("更新下载任务 %s 失败: %v", j.Name, err)
+	}
+	for _, ch := range d.waiters[j.ID] {
+		ch <- res
+	}
+	delete(d.waiters, j.ID)
+	delete(d.budgets, j.ID)
+	d.wake()
+}
+
+// execRetry 执行任务状态的更新，失败时短暂等待后重试，最多 3 次
+func execRetry(query string, args ...any) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+		}
+		if _, err = db.DB.Exec(query, args...); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+const jobColumns = `id, launcher, version, name, url, dir, host, size, digest, is_latest, status, attempts, strategy, bytes, error, not_before, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(r rowScanner) (Job, error) {
+	var j Job
+	var digest, strategy, errText sql.NullString
+	var notBefore, updatedAt int64
+	err := r.Scan(&j.ID, &j.Launcher, &j.Version, &j.Name, &j.URL, &j.Dir, &j.Host, &j.Size, &digest, &j.IsLatest,
+		&j.Status, &j.Attempts, &strategy, &j.Bytes, &errText, &notBefore, &updatedAt)
+	j.Digest = digest.String
+	j.Strategy = strategy.String
+	j.Error = errText.String
+	j.NotBefore = time.Unix(notBefore, 0)
+	j.UpdatedAt = time.Unix(updatedAt, 0)
+	return j, err
+}
+
+// Jobs 返回队列中的任务：未完成的任务全部返回，已完成的任务最多返回最近 limit 个
+func (d *Downloader) Jobs(limit int) ([]Job, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := db.DB.Query(`SELECT `+jobColumns+` FROM download_jobs WHERE status != ?
+        UNION ALL SELECT * FROM (SELECT `+jobColumns+` FROM download_jobs WHERE status = ? ORDER BY updated_at DESC LIMIT ?)`,
+		JobDone, JobDone, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []Job{}
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// Release 是与来源无关的版本描述。GitHub release 与网页抓取的结果都会转换为该结构后交给下载器。
+type Release struct {
+	ID          int64
+	TagName     string
+	Name        string
+	PublishedAt time.Time
+	Prerelease  bool
+	Body        string
+	Author      string
+	HTMLURL     string
+	Assets      []Asset
+}
+
+// Asset 描述一个上游资源文件，URL 为上游下载地址
+type Asset struct {
+	ID            int64
+	Name          string
+	URL           string
+	Size          int
+	ContentType   string
+	UpdatedAt     time.Time
+	DownloadCount int
+	// Digest 为上游提供的摘要，如 "sha256:<hex>"，未提供时为空
+	Digest string
+}
+
+// Version 返回用作目录名的版本号：优先使用 tag，其次使用名称，最后使用 ID
+func (r *Release) Version() string {
+	if r.TagName != "" {
+		return r.TagName
+	}
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("%d", r.ID)
+}
+
+// FromGitHub 将 GitHub release 转换为 Release，digests 为资源 ID 到上游摘要的映射，可以为 nil
+func FromGitHub(rel *github.RepositoryRelease, digests map[int64]string) *Release {
+	if rel == nil {
+		return nil
+	}
+	r := &Release{
+		ID:          rel.GetID(),
+		TagName:     rel.GetTagName(),
+		Name:        rel.GetName(),
+		PublishedAt: rel.GetPublishedAt().Time,
+		Prerelease:  rel.GetPrerelease(),
+		Body:        rel.GetBody(),
+		Author:      rel.GetAuthor().GetLogin(),
+		HTMLURL:     rel.GetHTMLURL(),
+	}
+	for _, a := range rel.Assets {
+		r.Assets = append(r.Assets, Asset{
+			ID:            a.GetID(),
+			Name:          a.GetName(),
+			URL:           a.GetBrowserDownloadURL(),
+			Size:          a.GetSize(),
+			ContentType:   a.GetContentType(),
+			UpdatedAt:     a.GetUpdatedAt().Time,
+			DownloadCount: a.GetDownloadCount(),
+			Digest:        digests[a.GetID()],
+		})
+	}
+	return r
+}
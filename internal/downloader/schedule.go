@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDeferred 表示有资源因不在下载时间窗口内而被推迟
+var ErrDeferred = errors.New("资源不在下载时间窗口内，已推迟")
+
+// Window 是一天中允许下载的时间段，以分钟计，End 小于 Start 时表示跨越午夜
+type Window struct {
+	Start int
+	End   int
+}
+
+// ParseWindow 解析 "02:00" 与 "07:00" 形式的起止时间
+func ParseWindow(start, end string) (Window, error) {
+	s, err := parseClock(start)
+	if err != nil {
+		return Window{}, err
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return Window{}, err
+	}
+	return Window{Start: s, End: e}, nil
+}
+
+func parseClock(v string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(v), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("无效的时间 %q，需要 HH:MM", v)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("无效的时间 %q，需要 HH:MM", v)
+	}
+	return h*60 + m, nil
+}
+
+func (w Window) contains(minute int) bool {
+	if w.Start == w.End {
+		return true
+	}
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// Schedule 限制大资源只能在指定时间窗口内下载。
+// 小于 MinSize 的资源以及（启用 BypassLatest 时）最新版本的资源不受限制。
+type Schedule struct {
+	Windows      []Window
+	MinSize      int64
+	BypassLatest bool
+	Location     *time.Location
+}
+
+// Allowed 判断大小为 size 的资源此刻能否下载
+func (s *Schedule) Allowed(size int64, isLatest bool, now time.Time) bool {
+	if s == nil || len(s.Windows) == 0 {
+		return true
+	}
+	if size < s.MinSize || (isLatest && s.BypassLatest) {
+		return true
+	}
+	now = s.in(now)
+	minute := now.Hour()*60 + now.Minute()
+	for _, w := range s.Windows {
+		if w.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen 返回 now 之后最近一个窗口的开始时间；当前已在窗口内时返回 now
+func (s *Schedule) NextOpen(now time.Time) time.Time {
+	if s == nil || len(s.Windows) == 0 {
+		return now
+	}
+	local := s.in(now)
+	minute := local.Hour()*60 + local.Minute()
+	best := time.Duration(-1)
+	for _, w := range s.Windows {
+		if w.contains(minute) {
+			return now
+		}
+		d := w.Start - minute
+		if d <= 0 {
+			d += 24 * 60
+		}
+		wait := time.Duration(d)*time.Minute - time.Duration(local.Second())*time.Second
+		if best < 0 || wait < best {
+			best = wait
+		}
+	}
+	return now.Add(best)
+}
+
+func (s *Schedule) in(t time.Time) time.Time {
+	if s.Location != nil {
+		return t.In(s.Location)
+	}
+	return t.Local()
+}
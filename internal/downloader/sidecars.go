@@ -0,0 +1,181 @@
+package downloader
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"lemwood_mirror/internal/metalink"
+	"lemwood_mirror/internal/torrent"
+)
+
+// 资源旁边生成的 P2P 元数据文件的扩展名
+const (
+	TorrentExt  = ".torrent"
+	MetalinkExt = ".meta4"
+)
+
+// IsSidecar 判断文件名是否为生成的种子或 Metalink 文件
+func IsSidecar(name string) bool {
+	return strings.HasSuffix(name, TorrentExt) || strings.HasSuffix(name, MetalinkExt)
+}
+
+// writeSidecars 在 dir 中为每个资源生成 .torrent（v1 + v2 混合）与 Metalink 4 文件，
+// 并把它们的地址与 info hash 记入 assets。只为有本站下载地址的资源生成；
+// 内容、下载地址与 tracker 都未变化时复用 final 中已发布的种子，不重新读取文件。
+// 生成失败只记录日志，不影响版本发布。
+func (d *Downloader) writeSidecars(dir, final string, assets []ReleaseAssetSimple, prev *ReleaseInfo) {
+	names := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		names[a.Name] = true
+	}
+	known := make(map[string]ReleaseAssetSimple)
+	if prev != nil {
+		for _, a := range prev.Assets {
+			known[a.Name] = a
+		}
+	}
+	for i := range assets {
+		a := &assets[i]
+		if a.UpstreamURL == "" || a.URL == "" || a.URL == a.UpstreamURL {
+			continue
+		}
+		if names[a.Name+TorrentExt] || names[a.Name+MetalinkExt] {
+			// 上游本身提供了同名的文件，不覆盖
+			continue
+		}
+		seeds := []string{a.URL, a.UpstreamURL}
+		torrentPath := filepath.Join(dir, a.Name+TorrentExt)
+
+		o, ok := known[a.Name]
+		reuse := ok && o.SHA256 != "" && o.SHA256 == a.SHA256 && o.InfoHash != "" &&
+			o.Magnet == torrent.Magnet(a.Name, o.InfoHash, o.InfoHashV2, seeds, d.Trackers)
+		if reuse && dir != final {
+			os.Remove(torrentPath)
+			reuse = os.Link(filepath.Join(final, a.Name+TorrentExt), torrentPath) == nil
+		} else if reuse {
+			reuse = fileExists(torrentPath)
+		}
+		if reuse {
+			a.InfoHash, a.InfoHashV2, a.Magnet = o.InfoHash, o.InfoHashV2, o.Magnet
+		} else {
+			t, err := torrent.Create(filepath.Join(dir, a.Name), torrent.Options{Name: a.Name, WebSeeds: seeds, Trackers: d.Trackers})
+			if err == nil {
+				err = writeFileAtomic(torrentPath, t.Data)
+			}
+			if err != nil {
+				log.Printf("生成资源 %s 的种子失败: %v", a.Name, err)
+				continue
+			}
+			a.InfoHash, a.InfoHashV2 = t.InfoHashV1, t.InfoHashV2
+			a.Magnet = torrent.Magnet(a.Name, t.InfoHashV1, t.InfoHashV2, seeds, d.Trackers)
+		}
+		a.TorrentURL = a.URL + TorrentExt
+
+		b, err := metalink.Marshal([]metalink.File{{
+			Name:    a.Name,
+			Size:    int64(a.Size),
+			SHA256:  a.SHA256,
+			URLs:    d.mirrorURLs(a.URL, a.UpstreamURL),
+			Torrent: a.TorrentURL,
+		}})
+		if err == nil {
+			err = writeFileAtomic(filepath.Join(dir, a.Name+MetalinkExt), b)
+		}
+		if err != nil {
+			log.Printf("生成资源 %s 的 Metalink 失败: %v", a.Name, err)
+			continue
+		}
+		a.MetalinkURL = a.URL + MetalinkExt
+	}
+}
+
+// mirrorURLs 返回 Metalink 中按优先级排列的下载地址：本站、上游，以及配置的代理与 Xget 地址
+func (d *Downloader) mirrorURLs(local, upstream string) []string {
+	urls := []string{local, upstream}
+	if d.Mirrors == nil {
+		return urls
+	}
+	seen := map[string]bool{local: true, upstream: true}
+	for _, s := range d.Mirrors.Ordered(upstream) {
+		if u, ok := s.Rewrite(upstream); ok && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// GenerateSidecars 为已发布但还没有种子或 Metalink 的版本补充生成，并更新其 index.json。
+// updated 在版本的 index.json 被改写后调用，用于刷新服务端缓存。返回补充的版本数。
+func (d *Downloader) GenerateSidecars(destBase string, updated func(launcher, version, infoPath string)) (int, error) {
+	launchers, err := os.ReadDir(destBase)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, l := range launchers {
+		if !l.IsDir() || IsHidden(l.Name()) {
+			continue
+		}
+		versions, err := os.ReadDir(filepath.Join(destBase, l.Name()))
+		if err != nil {
+			continue
+		}
+		for _, v := range versions {
+			if !v.IsDir() || IsHidden(v.Name()) {
+				continue
+			}
+			final := filepath.Join(destBase, l.Name(), v.Name())
+			info, err := readPublished(final)
+			if err != nil || !missingSidecars(final, info.Assets) {
+				continue
+			}
+			prev := *info
+			prev.Assets = append([]ReleaseAssetSimple(nil), info.Assets...)
+			d.writeSidecars(final, final, info.Assets, &prev)
+			infoPath := filepath.Join(final, "index.json")
+			if err := writeJSONAtomic(infoPath, info); err != nil {
+				log.Printf("%s: 更新版本 %s 的 index.json 失败: %v", l.Name(), v.Name(), err)
+				continue
+			}
+			count++
+			if updated != nil {
+				updated(l.Name(), v.Name(), infoPath)
+			}
+		}
+	}
+	return count, nil
+}
+
+// missingSidecars 判断版本中是否有应当生成但缺少种子或 Metalink 的资源
+func missingSidecars(final string, assets []ReleaseAssetSimple) bool {
+	names := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		names[a.Name] = true
+	}
+	for _, a := range assets {
+		if a.UpstreamURL == "" || a.URL == "" || a.URL == a.UpstreamURL || names[a.Name+TorrentExt] || names[a.Name+MetalinkExt] {
+			continue
+		}
+		if a.TorrentURL == "" || a.MetalinkURL == "" ||
+			!fileExists(filepath.Join(final, a.Name+TorrentExt)) || !fileExists(filepath.Join(final, a.Name+MetalinkExt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileAtomic 先写入同目录下的隐藏临时文件再重命名，读取方不会看到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
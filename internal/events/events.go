@@ -0,0 +1,92 @@
+package events
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"lemwood_mirror/internal/db"
+)
+
+// 事件级别
+const (
+	LevelInfo  = "info"
+	LevelAlert = "alert"
+)
+
+// 事件类型
+const (
+	KindRepoChanged     = "repo_changed"     // 源页面指向了不同的仓库，可能被劫持
+	KindRepoRenamed     = "repo_renamed"     // 上游仓库被重命名或转移
+	KindStale           = "stale"            // 启动器长时间未能同步上游的最新版本
+	KindRecovered       = "recovered"        // 启动器恢复同步
+	KindAssetChanged    = "asset_changed"    // 已镜像的资源在上游被重新上传
+	KindUpstreamDeleted = "upstream_deleted" // 上游删除了已镜像的 release
+	KindPeerMismatch    = "peer_mismatch"    // 联邦节点上的资源与本节点内容不一致
+	KindDiskSpace       = "disk_space"       // 磁盘空间或配额不足，拒绝下载新版本
+)
+
+// Event 是一条持久化的运行事件或告警
+type Event struct {
+	ID        int64     `json:"id"`
+	Level     string    `json:"level"`
+	Kind      string    `json:"kind"`
+	Launcher  string    `json:"launcher"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Info 记录一条普通事件
+func Info(kind, launcher, format string, args ...any) {
+	emit(LevelInfo, kind, launcher, fmt.Sprintf(format, args...))
+}
+
+// Alert 记录一条需要运维人员关注的告警
+func Alert(kind, launcher, format string, args ...any) {
+	emit(LevelAlert, kind, launcher, fmt.Sprintf(format, args...))
+}
+
+func emit(level, kind, launcher, message string) {
+	if level == LevelAlert {
+		log.Printf("告警 [%s] %s: %s", kind, launcher, message)
+	} else {
+		log.Printf("事件 [%s] %s: %s", kind, launcher, message)
+	}
+	if db.DB == nil {
+		return
+	}
+	_, err := db.DB.Exec(`INSERT INTO events (level, kind, launcher, message) VALUES (?, ?, ?, ?)`,
+		level, kind, launcher, message)
+	if err != nil {
+		log.Printf("记录事件失败: %v", err)
+	}
+}
+
+// Recent 按时间倒序返回最近的事件，level/kind/launcher 为空时不过滤
+func Recent(level, kind, launcher string, limit int) ([]Event, error) {
+	list := []Event{}
+	if db.DB == nil {
+		return list, nil
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := db.DB.Query(`
+        SELECT id, level, kind, launcher, message, created_at
+        FROM events
+        WHERE (? = '' OR level = ?) AND (? = '' OR kind = ?) AND (? = '' OR launcher = ?)
+        ORDER BY id DESC
+        LIMIT ?`, level, level, kind, kind, launcher, launcher, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Level, &e.Kind, &e.Launcher, &e.Message, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
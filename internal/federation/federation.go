@@ -0,0 +1,383 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lemwood_mirror/internal/db"
+	"lemwood_mirror/internal/events"
+)
+
+// Peer 是联邦中的一个镜像节点
+type Peer struct {
+	Name      string
+	URL       string // 节点根地址，不带结尾斜杠
+	Region    string
+	Countries []string // 由该节点提供的国家代码或名称，为空时作为其他国家的后备节点
+	Weight    int
+}
+
+// File 是一个已发布的资源及其内容摘要
+type File struct {
+	Launcher string
+	Version  string
+	Name     string
+	Size     int64
+	SHA256   string
+}
+
+func (f File) key() string {
+	return f.Launcher + "/" + f.Version + "/" + f.Name
+}
+
+// 由本节点提供下载的原因
+const (
+	FallbackLocalCountry   = "local_country"   // 客户端所在国家由本节点提供
+	FallbackUnknownCountry = "unknown_country" // 无法确定客户端所在国家
+	FallbackNoPeer         = "no_peer"         // 没有为该国家配置节点
+	FallbackUnavailable    = "unavailable"     // 对应节点不健康或没有内容一致的文件
+)
+
+// PeerStatus 是节点的健康状况、内容检查结果与重定向统计
+type PeerStatus struct {
+	Name         string    `json:"name"`
+	URL          string    `json:"url"`
+	Region       string    `json:"region,omitempty"`
+	Countries    []string  `json:"countries"`
+	Weight       int       `json:"weight"`
+	Healthy      bool      `json:"healthy"`
+	LastCheck    time.Time `json:"last_check"`
+	LastError    string    `json:"last_error,omitempty"`
+	LatencyMS    int64     `json:"latency_ms"`
+	Matched      int       `json:"matched"`    // 与本节点内容一致的资源数
+	Missing      int       `json:"missing"`    // 节点上还没有的资源数
+	Mismatched   int       `json:"mismatched"` // 大小或 SHA-256 与本节点不同的资源数
+	Redirects    int64     `json:"redirects"`
+	Redirects24h int64     `json:"redirects_24h"`
+}
+
+// Status 是联邦的整体状况
+type Status struct {
+	LocalCountries []string         `json:"local_countries"`
+	Peers          []PeerStatus     `json:"peers"`
+	Fallbacks      map[string]int64 `json:"fallbacks"` // 进程启动以来按原因统计的本地提供次数
+}
+
+// Federation 检查联邦中其他节点的健康状况与内容，并为下载请求选择重定向的节点
+type Federation struct {
+	// Local 返回本节点已发布的全部资源，用于检查节点内容是否一致
+	Local func() []File
+
+	peers  []*peer
+	local  map[string]bool
+	client *http.Client
+
+	mu        sync.Mutex
+	fallbacks map[string]int64
+}
+
+type peer struct {
+	Peer
+	countries map[string]bool
+
+	mu         sync.RWMutex
+	healthy    bool
+	lastCheck  time.Time
+	lastError  string
+	latency    time.Duration
+	files      map[string]File
+	matched    int
+	missing    int
+	mismatched int
+}
+
+// New 创建联邦，localCountries 中的客户端总是由本节点提供
+func New(peers []Peer, localCountries []string) *Federation {
+	f := &Federation{
+		local:     countrySet(localCountries),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		fallbacks: make(map[string]int64),
+	}
+	for _, p := range peers {
+		f.peers = append(f.peers, &peer{Peer: p, countries: countrySet(p.Countries)})
+	}
+	return f
+}
+
+func countrySet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, c := range list {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			set[c] = true
+		}
+	}
+	return set
+}
+
+// Start 立即检查一次全部节点，之后每隔 interval 检查
+func (f *Federation) Start(interval time.Duration) {
+	go func() {
+		f.Check(context.Background())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			f.Check(context.Background())
+		}
+	}()
+}
+
+// Check 并发获取每个节点的 /api/status，记录健康状况并与本节点的资源逐一比较
+func (f *Federation) Check(ctx context.Context) {
+	var local []File
+	if f.Local != nil {
+		local = f.Local()
+	}
+	var wg sync.WaitGroup
+	for _, p := range f.peers {
+		wg.Add(1)
+		go func(p *peer) {
+			defer wg.Done()
+			f.checkPeer(ctx, p, local)
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (f *Federation) checkPeer(ctx context.Context, p *peer, local []File) {
+	start := time.Now()
+	files, err := f.fetchFiles(ctx, p.URL)
+	latency := time.Since(start)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	wasHealthy, hadMismatch := p.healthy, p.mismatched > 0
+	p.lastCheck = time.Now()
+	p.latency = latency
+	if err != nil {
+		p.healthy = false
+		p.lastError = err.Error()
+		if wasHealthy {
+			log.Printf("联邦节点 %s 不可用: %v", p.Name, err)
+		}
+		return
+	}
+	p.healthy = true
+	p.lastError = ""
+	p.files = files
+	p.matched, p.missing, p.mismatched = 0, 0, 0
+	for _, want := range local {
+		got, ok := files[want.key()]
+		switch {
+		case !ok:
+			p.missing++
+		case sameContent(want, got):
+			p.matched++
+		default:
+			p.mismatched++
+		}
+	}
+	if !wasHealthy {
+		log.Printf("联邦节点 %s 可用，%d 个资源一致，%d 个缺失，%d 个不一致", p.Name, p.matched, p.missing, p.mismatched)
+	}
+	if p.mismatched > 0 && !hadMismatch {
+		events.Alert(events.KindPeerMismatch, "", "联邦节点 %s 有 %d 个资源与本节点内容不一致，这些资源不会重定向到该节点", p.Name, p.mismatched)
+	}
+}
+
+// fetchFiles 读取节点 /api/status 中列出的全部资源
+func (f *Federation) fetchFiles(ctx context.Context, base string) (map[string]File, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("/api/status 返回 %s", resp.Status)
+	}
+	var status map[string][]struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name   string `json:"name"`
+			Size   int64  `json:"size"`
+			SHA256 string `json:"sha256"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("解析 /api/status 失败: %w", err)
+	}
+	files := make(map[string]File)
+	for launcher, versions := range status {
+		for _, v := range versions {
+			for _, a := range v.Assets {
+				file := File{Launcher: launcher, Version: v.TagName, Name: a.Name, Size: a.Size, SHA256: a.SHA256}
+				files[file.key()] = file
+			}
+		}
+	}
+	return files, nil
+}
+
+// sameContent 优先比较 SHA-256，任一方没有记录时比较大小
+func sameContent(want, got File) bool {
+	if want.SHA256 != "" && got.SHA256 != "" {
+		return strings.EqualFold(want.SHA256, got.SHA256)
+	}
+	return want.Size > 0 && want.Size == got.Size
+}
+
+// Route 为来自 country（国家代码与名称）的客户端选择提供 want 的节点，返回重定向地址。
+// 优先选择列出该国家的节点，没有时使用未列出国家的后备节点；只选择健康且文件内容与本节点一致的节点，
+// 同时满足条件的节点按权重随机选择。返回空字符串表示由本节点提供。
+func (f *Federation) Route(code, name string, want File) string {
+	code, name = strings.ToUpper(code), strings.ToUpper(name)
+	switch {
+	case code == "" && name == "":
+		f.fallback(FallbackUnknownCountry)
+		return ""
+	case code == "LOCAL" || f.local[code] || f.local[name]:
+		f.fallback(FallbackLocalCountry)
+		return ""
+	}
+	var candidates []*peer
+	for _, p := range f.peers {
+		if p.countries[code] || p.countries[name] {
+			candidates = append(candidates, p)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, p := range f.peers {
+			if len(p.countries) == 0 {
+				candidates = append(candidates, p)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		f.fallback(FallbackNoPeer)
+		return ""
+	}
+
+	var eligible []*peer
+	total := 0
+	for _, p := range candidates {
+		p.mu.RLock()
+		got, ok := p.files[want.key()]
+		ok = ok && p.healthy && p.Weight > 0 && sameContent(want, got)
+		p.mu.RUnlock()
+		if ok {
+			eligible = append(eligible, p)
+			total += p.Weight
+		}
+	}
+	if len(eligible) == 0 {
+		f.fallback(FallbackUnavailable)
+		return ""
+	}
+	chosen := eligible[0]
+	n := rand.IntN(total)
+	for _, p := range eligible {
+		if n < p.Weight {
+			chosen = p
+			break
+		}
+		n -= p.Weight
+	}
+	record(chosen.Name, want, code)
+	// 节点不再重定向，避免节点之间互相转发
+	return fmt.Sprintf("%s/download/%s/%s/%s?noredirect=1", chosen.URL,
+		url.PathEscape(want.Launcher), url.PathEscape(want.Version), url.PathEscape(want.Name))
+}
+
+func (f *Federation) fallback(reason string) {
+	f.mu.Lock()
+	f.fallbacks[reason]++
+	f.mu.Unlock()
+}
+
+// record 异步记录一次重定向
+func record(peerName string, file File, country string) {
+	if db.DB == nil {
+		return
+	}
+	go func() {
+		_, err := db.DB.Exec(`INSERT INTO redirects (peer, launcher, version, file_name, country) VALUES (?, ?, ?, ?, ?)`,
+			peerName, file.Launcher, file.Version, file.Name, country)
+		if err != nil {
+			log.Printf("记录重定向失败: %v", err)
+		}
+	}()
+}
+
+// Status 返回各节点的状况与重定向统计
+func (f *Federation) Status() (Status, error) {
+	st := Status{LocalCountries: []string{}, Peers: []PeerStatus{}, Fallbacks: make(map[string]int64)}
+	for c := range f.local {
+		st.LocalCountries = append(st.LocalCountries, c)
+	}
+	sort.Strings(st.LocalCountries)
+	f.mu.Lock()
+	for k, v := range f.fallbacks {
+		st.Fallbacks[k] = v
+	}
+	f.mu.Unlock()
+
+	type counts struct{ total, recent int64 }
+	redirects := make(map[string]counts)
+	if db.DB != nil {
+		rows, err := db.DB.Query(`SELECT peer, COUNT(*), COALESCE(SUM(created_at >= datetime('now', '-1 day')), 0)
+            FROM redirects GROUP BY peer`)
+		if err != nil {
+			return st, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			var c counts
+			if err := rows.Scan(&name, &c.total, &c.recent); err != nil {
+				return st, err
+			}
+			redirects[name] = c
+		}
+		if err := rows.Err(); err != nil {
+			return st, err
+		}
+	}
+
+	for _, p := range f.peers {
+		p.mu.RLock()
+		ps := PeerStatus{
+			Name:         p.Name,
+			URL:          p.URL,
+			Region:       p.Region,
+			Countries:    append([]string{}, p.Countries...),
+			Weight:       p.Weight,
+			Healthy:      p.healthy,
+			LastCheck:    p.lastCheck,
+			LastError:    p.lastError,
+			LatencyMS:    p.latency.Milliseconds(),
+			Matched:      p.matched,
+			Missing:      p.missing,
+			Mismatched:   p.mismatched,
+			Redirects:    redirects[p.Name].total,
+			Redirects24h: redirects[p.Name].recent,
+		}
+		p.mu.RUnlock()
+		st.Peers = append(st.Peers, ps)
+	}
+	return st, nil
+}
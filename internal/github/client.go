@@ -2,17 +2,25 @@ package gh
 
 import (
     "context"
+    "encoding/json"
     "errors"
+    "fmt"
     "net/http"
+    "net/url"
+    "regexp"
     "strings"
     "time"
 
     github "github.com/google/go-github/v50/github"
     "golang.org/x/oauth2"
+
+    "lemwood_mirror/internal/retry"
 )
 
 type Client struct {
 	cli *github.Client
+	// Retry 为 API 请求的重试策略，零值使用默认策略
+	Retry retry.Policy
 }
 
 func NewClient(token string) *Client {
@@ -26,32 +34,143 @@ func NewClient(token string) *Client {
 	return &Client{cli: github.NewClient(httpClient)}
 }
 
-// ParseOwnerRepo 从完整的 GitHub 仓库 URL 中提取所有者和仓库名。
+// ParseOwnerRepo 从 GitHub 仓库 URL 中提取所有者和仓库名。
+// 支持 https://github.com/<owner>/<repo> 及其常见变体：http 协议、www 前缀、省略协议、
+// .git 后缀、结尾斜杠、查询参数与锚点、额外路径（/tree/main、/releases 等），
+// 以及 git@github.com:<owner>/<repo>.git、ssh:// 与 git:// 形式的克隆地址。
 func ParseOwnerRepo(repoURL string) (string, string, error) {
-	// 预期格式：https://github.com/<owner>/<repo>
-	parts := strings.Split(strings.TrimPrefix(repoURL, "https://github.com/"), "/")
-	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
-		return "", "", errors.New("无效的仓库 url，需要 https://github.com/<owner>/<repo>")
+	errInvalid := errors.New("无效的仓库 url，需要 https://github.com/<owner>/<repo>")
+	raw := strings.TrimSpace(repoURL)
+	if raw == "" {
+		return "", "", errInvalid
+	}
+	// scp 风格的 SSH 地址：git@github.com:owner/repo.git
+	if strings.HasPrefix(raw, "git@") && !strings.Contains(raw, "://") {
+		raw = "ssh://" + strings.Replace(raw, ":", "/", 1)
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", errInvalid
+	}
+	host := strings.ToLower(u.Hostname())
+	if host != "github.com" && host != "www.github.com" {
+		return "", "", errInvalid
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return "", "", errInvalid
 	}
 	owner := parts[0]
-	repo := parts[1]
+	repo := strings.TrimSuffix(parts[1], ".git")
+	if !ownerRe.MatchString(owner) || !repoRe.MatchString(repo) || repo == "." || repo == ".." || reservedOwners[strings.ToLower(owner)] {
+		return "", "", errInvalid
+	}
 	return owner, repo, nil
 }
 
-// LatestRelease 仅获取最新的发布元数据。
-func (c *Client) LatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error) {
-    return c.cli.Repositories.GetLatestRelease(ctx, owner, repo)
+// NormalizeRepoURL 将各种形式的 GitHub 仓库地址规范化为 https://github.com/<owner>/<repo>
+func NormalizeRepoURL(repoURL string) (string, error) {
+	owner, repo, err := ParseOwnerRepo(repoURL)
+	if err != nil {
+		return "", err
+	}
+	return "https://github.com/" + owner + "/" + repo, nil
+}
+
+var (
+	ownerRe = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+	repoRe  = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+	// github.com 下不是用户或组织的顶级路径
+	reservedOwners = map[string]bool{
+		"about": true, "apps": true, "collections": true, "customer-stories": true, "enterprise": true,
+		"explore": true, "features": true, "login": true, "marketplace": true, "notifications": true,
+		"orgs": true, "pricing": true, "pulls": true, "search": true, "settings": true,
+		"sponsors": true, "topics": true, "trending": true, "issues": true, "security": true,
+	}
+)
+
+// Release 是带有资源摘要的 GitHub release。go-github 尚未解析资源的 digest 字段，需要单独读取。
+type Release struct {
+	*github.RepositoryRelease
+	// Digests 为资源 ID 到上游摘要（如 "sha256:<hex>"）的映射，上游未提供摘要的资源不在其中
+	Digests map[int64]string
+}
+
+// LatestRelease 仅获取最新的发布元数据。暂时错误与速率限制按重试策略重试。
+func (c *Client) LatestRelease(ctx context.Context, owner, repo string) (*Release, *github.Response, error) {
+	return c.getRelease(ctx, fmt.Sprintf("repos/%v/%v/releases/latest", owner, repo))
 }
 
-// BackoffIfRateLimited 检查响应是否受到速率限制，并在需要时休眠。
-func BackoffIfRateLimited(resp *github.Response) {
-    if resp == nil || resp.Rate.Remaining > 0 {
-        return
-    }
-	reset := resp.Rate.Reset.Time
-	// 休眠直到重置时间 + 小段余量
-	d := time.Until(reset) + 2*time.Second
-	if d > 0 && d < 15*time.Minute {
-		time.Sleep(d)
+// ReleaseByTag 获取指定 tag 的发布元数据，重试方式与 LatestRelease 相同
+func (c *Client) ReleaseByTag(ctx context.Context, owner, repo, tag string) (*Release, *github.Response, error) {
+	return c.getRelease(ctx, fmt.Sprintf("repos/%v/%v/releases/tags/%v", owner, repo, url.PathEscape(tag)))
+}
+
+func (c *Client) getRelease(ctx context.Context, path string) (*Release, *github.Response, error) {
+	var raw json.RawMessage
+	var resp *github.Response
+	err := c.Retry.Do(ctx, func(int) error {
+		req, err := c.cli.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		raw = nil
+		resp, err = c.cli.Do(ctx, req, &raw)
+		return classify(resp, err)
+	})
+	if err != nil {
+		return nil, resp, err
+	}
+	rel, err := decodeRelease(raw)
+	return rel, resp, err
+}
+
+// decodeRelease 解析 release 的 JSON，同时取出各资源的 digest 字段
+func decodeRelease(raw []byte) (*Release, error) {
+	rel := &Release{RepositoryRelease: new(github.RepositoryRelease), Digests: make(map[int64]string)}
+	if err := json.Unmarshal(raw, rel.RepositoryRelease); err != nil {
+		return nil, fmt.Errorf("解析 release 失败: %w", err)
+	}
+	var digests struct {
+		Assets []struct {
+			ID     int64  `json:"id"`
+			Digest string `json:"digest"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(raw, &digests); err != nil {
+		return nil, fmt.Errorf("解析 release 失败: %w", err)
+	}
+	for _, a := range digests.Assets {
+		if a.Digest != "" {
+			rel.Digests[a.ID] = a.Digest
+		}
+	}
+	return rel, nil
+}
+
+// classify 将 go-github 的错误转换为可供重试策略判断的错误。
+// 速率限制按重置时间或 Retry-After 等待，其余按响应状态码区分永久与暂时错误。
+func classify(resp *github.Response, err error) error {
+	if err == nil {
+		return nil
+	}
+	var rle *github.RateLimitError
+	if errors.As(err, &rle) {
+		return &retry.HTTPError{StatusCode: http.StatusForbidden, RetryAfter: time.Until(rle.Rate.Reset.Time) + 2*time.Second, Err: err}
+	}
+	var arle *github.AbuseRateLimitError
+	if errors.As(err, &arle) {
+		wait := time.Minute
+		if arle.RetryAfter != nil {
+			wait = *arle.RetryAfter
+		}
+		return &retry.HTTPError{StatusCode: http.StatusForbidden, RetryAfter: wait, Err: err}
+	}
+	if resp != nil && resp.Response != nil {
+		return retry.FromResponse(resp.Response, err)
 	}
+	return err
 }
@@ -0,0 +1,376 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ToHTML 将 release 说明中的 Markdown 渲染为经过净化的 HTML。
+// 所有原始文本都会先转义，因此正文中的 HTML 标签不会生效；
+// 链接和图片仅允许 http、https 与 mailto 协议。
+// 支持的语法：标题、段落、引用、有序/无序列表、围栏代码块、分隔线，
+// 以及行内代码、粗体、斜体、删除线、链接、图片和裸链接。
+func ToHTML(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"))
+	return b.String()
+}
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	hrRe        = regexp.MustCompile(`^ {0,3}([-*_])( *[-*_]){2,} *$`)
+	ulItemRe    = regexp.MustCompile(`^ {0,3}[-*+]\s+(.*)$`)
+	olItemRe    = regexp.MustCompile(`^ {0,3}\d{1,9}[.)]\s+(.*)$`)
+	fenceRe     = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([^`\\s]*)")
+	blockquoteR = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+)
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case fenceRe.MatchString(line):
+			m := fenceRe.FindStringSubmatch(line)
+			fence := m[1]
+			lang := m[2]
+			i++
+			var code []string
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // 跳过结束围栏
+			if lang != "" {
+				b.WriteString(`<pre><code class="language-` + html.EscapeString(lang) + `">`)
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case headingRe.MatchString(trimmed):
+			m := headingRe.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case hrRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case blockquoteR.MatchString(line):
+			var quoted []string
+			for i < len(lines) && blockquoteR.MatchString(lines[i]) {
+				quoted = append(quoted, blockquoteR.FindStringSubmatch(lines[i])[1])
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case ulItemRe.MatchString(line):
+			i = renderList(b, lines, i, ulItemRe, "ul")
+
+		case olItemRe.MatchString(line):
+			i = renderList(b, lines, i, olItemRe, "ol")
+
+		default:
+			var para []string
+			for i < len(lines) && isParagraphLine(lines[i]) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			b.WriteString("<p>" + renderInline(strings.Join(para, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// renderList 渲染一个连续的列表，缩进的后续行归入上一个列表项（支持嵌套）。
+func renderList(b *strings.Builder, lines []string, i int, itemRe *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag + ">\n")
+	for i < len(lines) {
+		m := itemRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		item := []string{m[1]}
+		i++
+		for i < len(lines) {
+			next := lines[i]
+			if strings.TrimSpace(next) == "" {
+				// 空行后若仍是缩进内容则继续属于当前项
+				if i+1 < len(lines) && isIndented(lines[i+1]) {
+					item = append(item, "")
+					i++
+					continue
+				}
+				break
+			}
+			if isIndented(next) {
+				item = append(item, strings.TrimLeft(next, " \t"))
+				i++
+				continue
+			}
+			if itemRe.MatchString(next) || !isParagraphLine(next) {
+				break
+			}
+			// 懒惰续行
+			item = append(item, strings.TrimSpace(next))
+			i++
+		}
+		b.WriteString("<li>")
+		if len(item) == 1 {
+			b.WriteString(renderInline(item[0]))
+		} else {
+			renderListItem(b, item)
+		}
+		b.WriteString("</li>\n")
+		if i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) && itemRe.MatchString(lines[i+1]) {
+			i++
+		}
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderListItem 渲染多行列表项：首段作为行内文本，其余内容按块级元素渲染。
+func renderListItem(b *strings.Builder, item []string) {
+	j := 0
+	var first []string
+	for j < len(item) && item[j] != "" && !ulItemRe.MatchString(item[j]) && !olItemRe.MatchString(item[j]) {
+		first = append(first, item[j])
+		j++
+	}
+	b.WriteString(renderInline(strings.Join(first, "\n")))
+	if j < len(item) {
+		b.WriteString("\n")
+		renderBlocks(b, item[j:])
+	}
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+}
+
+func isParagraphLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return false
+	}
+	return !fenceRe.MatchString(line) &&
+		!headingRe.MatchString(trimmed) &&
+		!hrRe.MatchString(line) &&
+		!blockquoteR.MatchString(line) &&
+		!ulItemRe.MatchString(line) &&
+		!olItemRe.MatchString(line)
+}
+
+// renderInline 渲染行内元素，所有普通文本都会被转义。
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!~>|", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\n':
+			b.WriteString("<br>\n")
+			i++
+			continue
+
+		case c == '`':
+			run := countRun(s[i:], '`')
+			if end := strings.Index(s[i+run:], strings.Repeat("`", run)); end >= 0 {
+				code := strings.TrimSpace(s[i+run : i+run+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += run + end + run
+				continue
+			}
+
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, n, ok := parseLink(s[i+1:]); ok {
+				if u, ok := safeURL(dest); ok {
+					b.WriteString(`<img src="` + html.EscapeString(u) + `" alt="` + html.EscapeString(text) + `">`)
+				} else {
+					b.WriteString(html.EscapeString(text))
+				}
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if text, dest, n, ok := parseLink(s[i:]); ok {
+				if u, ok := safeURL(dest); ok {
+					b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + renderInline(text) + `</a>`)
+				} else {
+					b.WriteString(renderInline(text))
+				}
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n, out, ok := parseEmphasis(s, i); ok {
+				b.WriteString(out)
+				i += n
+				continue
+			}
+
+		case c == 'h' && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")) && (i == 0 || !isWordByte(s[i-1])):
+			end := i
+			for end < len(s) && !isSpaceByte(s[end]) && s[end] != '<' {
+				end++
+			}
+			raw := strings.TrimRight(s[i:end], ".,;:!?)\"'")
+			if u, ok := safeURL(raw); ok && len(raw) > len("https://") {
+				b.WriteString(`<a href="` + html.EscapeString(u) + `" rel="nofollow noopener noreferrer">` + html.EscapeString(raw) + `</a>`)
+				i += len(raw)
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// parseEmphasis 解析 **粗体**、*斜体*、__粗体__、_斜体_ 与 ~~删除线~~。
+func parseEmphasis(s string, i int) (int, string, bool) {
+	c := s[i]
+	run := countRun(s[i:], c)
+	var delim, tag string
+	switch {
+	case c == '~' && run >= 2:
+		delim, tag = "~~", "del"
+	case c == '~':
+		return 0, "", false
+	case run >= 2:
+		delim, tag = string([]byte{c, c}), "strong"
+	default:
+		delim, tag = string(c), "em"
+	}
+	// 下划线只在单词边界处生效，避免误伤 snake_case 之类的标识符
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, "", false
+	}
+	start := i + len(delim)
+	if start >= len(s) || isSpaceByte(s[start]) {
+		return 0, "", false
+	}
+	for j := start; j+len(delim) <= len(s); j++ {
+		if s[j] == '`' {
+			// 跳过行内代码
+			if end := strings.IndexByte(s[j+1:], '`'); end >= 0 {
+				j += end + 1
+				continue
+			}
+		}
+		if !strings.HasPrefix(s[j:], delim) || isSpaceByte(s[j-1]) {
+			continue
+		}
+		if c == '_' && j+len(delim) < len(s) && isWordByte(s[j+len(delim)]) {
+			continue
+		}
+		inner := s[start:j]
+		return j + len(delim) - i, "<" + tag + ">" + renderInline(inner) + "</" + tag + ">", true
+	}
+	return 0, "", false
+}
+
+// parseLink 解析 [text](dest) 形式的链接，返回消耗的字节数。
+func parseLink(s string) (text, dest string, n int, ok bool) {
+	if len(s) == 0 || s[0] != '[' {
+		return "", "", 0, false
+	}
+	depth := 0
+	closeText := -1
+	for j := 0; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = j
+			}
+		}
+		if closeText >= 0 {
+			break
+		}
+	}
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return "", "", 0, false
+	}
+	end := -1
+	parens := 0
+	for j, ch := range s[closeText+2:] {
+		if ch == '(' {
+			parens++
+		} else if ch == ')' {
+			if parens == 0 {
+				end = j
+				break
+			}
+			parens--
+		}
+	}
+	if end < 0 {
+		return "", "", 0, false
+	}
+	dest = strings.TrimSpace(s[closeText+2 : closeText+2+end])
+	// 去掉可选的标题部分：[text](url "title")
+	if sp := strings.IndexAny(dest, " \t"); sp >= 0 {
+		dest = dest[:sp]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return s[1:closeText], dest, closeText + 2 + end + 1, true
+}
+
+// safeURL 仅放行 http、https、mailto 协议以及相对锚点。
+func safeURL(raw string) (string, bool) {
+	if raw == "" {
+		return "", false
+	}
+	if strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return u.String(), true
+	}
+	return "", false
+}
+
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
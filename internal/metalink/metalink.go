@@ -0,0 +1,72 @@
+package metalink
+
+import "encoding/xml"
+
+// Namespace 是 Metalink 4（RFC 5854）的 XML 命名空间
+const Namespace = "urn:ietf:params:xml:ns:metalink"
+
+// MediaType 是 .meta4 文件的媒体类型
+const MediaType = "application/metalink4+xml"
+
+// File 描述 Metalink 中的一个文件
+type File struct {
+	Name    string
+	Size    int64
+	SHA256  string   // 十六进制
+	URLs    []string // 按优先级排列的下载地址
+	Torrent string   // 对应 .torrent 的地址，可为空
+}
+
+type document struct {
+	XMLName   xml.Name  `xml:"metalink"`
+	XMLNS     string    `xml:"xmlns,attr"`
+	Generator string    `xml:"generator"`
+	Files     []fileXML `xml:"file"`
+}
+
+type fileXML struct {
+	Name     string       `xml:"name,attr"`
+	Size     int64        `xml:"size,omitempty"`
+	Hashes   []hashXML    `xml:"hash"`
+	URLs     []urlXML     `xml:"url"`
+	MetaURLs []metaURLXML `xml:"metaurl"`
+}
+
+type hashXML struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type urlXML struct {
+	Priority int    `xml:"priority,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type metaURLXML struct {
+	MediaType string `xml:"mediatype,attr"`
+	Priority  int    `xml:"priority,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// Marshal 生成包含 files 的 Metalink 4 文档。URL 的优先级按顺序从 1 开始递增（数值越小越优先）。
+func Marshal(files []File) ([]byte, error) {
+	doc := document{XMLNS: Namespace, Generator: "lemwood_mirror"}
+	for _, f := range files {
+		fx := fileXML{Name: f.Name, Size: f.Size}
+		if f.SHA256 != "" {
+			fx.Hashes = append(fx.Hashes, hashXML{Type: "sha-256", Value: f.SHA256})
+		}
+		for i, u := range f.URLs {
+			fx.URLs = append(fx.URLs, urlXML{Priority: i + 1, Value: u})
+		}
+		if f.Torrent != "" {
+			fx.MetaURLs = append(fx.MetaURLs, metaURLXML{MediaType: "torrent", Priority: 1, Value: f.Torrent})
+		}
+		doc.Files = append(doc.Files, fx)
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
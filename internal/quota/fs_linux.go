@@ -0,0 +1,28 @@
+//go:build linux
+
+package quota
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// FreeSpace 返回 path 所在文件系统中非特权用户可用的字节数
+func FreeSpace(path string) (int64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+// fileID 返回文件的设备号与 inode，用于只统计一次硬链接
+func fileID(fi os.FileInfo) (uint64, uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(st.Dev), uint64(st.Ino), true
+}
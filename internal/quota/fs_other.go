@@ -0,0 +1,21 @@
+//go:build !linux
+
+package quota
+
+import (
+	"errors"
+	"os"
+)
+
+// errUnsupported 表示当前系统不支持查询可用空间，调用方跳过可用空间检查
+var errUnsupported = errors.New("不支持查询可用空间")
+
+// FreeSpace 在不支持 statfs 的系统上总是失败
+func FreeSpace(path string) (int64, error) {
+	return 0, errUnsupported
+}
+
+// fileID 在其他系统上不区分硬链接，每个路径单独统计
+func fileID(fi os.FileInfo) (uint64, uint64, bool) {
+	return 0, 0, false
+}
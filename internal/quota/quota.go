@@ -0,0 +1,261 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"lemwood_mirror/internal/events"
+)
+
+// ErrInsufficientSpace 表示下载会超出文件系统的可用空间或配置的配额
+var ErrInsufficientSpace = errors.New("磁盘空间不足")
+
+// Refusal 记录启动器最近一次因空间不足被拒绝的下载
+type Refusal struct {
+	Launcher string    `json:"launcher"`
+	Version  string    `json:"version"`
+	Reason   string    `json:"reason"`
+	Need     int64     `json:"need_bytes"`
+	At       time.Time `json:"at"`
+	Count    int       `json:"count"` // 空间恢复之前连续被拒绝的次数
+}
+
+// LauncherUsage 是单个启动器目录的占用情况
+type LauncherUsage struct {
+	Name          string `json:"name"`
+	UsedBytes     int64  `json:"used_bytes"`
+	QuotaBytes    int64  `json:"quota_bytes"`
+	ReservedBytes int64  `json:"reserved_bytes"`
+}
+
+// Status 是存储目录的占用、配额与拒绝统计
+type Status struct {
+	FreeBytes     *int64          `json:"free_bytes"` // 无法查询时为 null
+	MinFreeBytes  int64           `json:"min_free_bytes"`
+	UsedBytes     int64           `json:"used_bytes"`
+	QuotaBytes    int64           `json:"quota_bytes"`
+	ReservedBytes int64           `json:"reserved_bytes"` // 正在下载的版本预留的空间
+	Launchers     []LauncherUsage `json:"launchers"`
+	Refusals      []Refusal       `json:"refusals"`
+	RefusedTotal  int64           `json:"refused_total"`
+}
+
+// Guard 在下载新版本之前检查可用空间与配额。
+// 通过检查的下载会预留所需空间直到结束，并发下载的多个启动器不会同时占用同一块可用空间。
+type Guard struct {
+	// Total 为存储目录的总配额（字节），0 表示不限制
+	Total int64
+	// MinFree 为下载后文件系统至少保留的可用空间（字节），负数表示不检查可用空间
+	MinFree int64
+	// LauncherQuota 返回启动器目录的配额（字节），0 表示不限制；nil 表示没有单独的配额
+	LauncherQuota func(launcher string) int64
+	// Reclaim 在空间不足时按保留策略为启动器腾出空间，之后重新检查；nil 表示直接拒绝
+	Reclaim func(launcher string)
+
+	base     string
+	mu       sync.Mutex // 串行化检查与预留
+	reserved map[string]int64
+	refusals map[string]Refusal
+	refused  int64
+}
+
+// New 创建检查 base 存储目录的 Guard，默认不限制配额也不检查可用空间
+func New(base string) *Guard {
+	return &Guard{
+		MinFree:  -1,
+		base:     base,
+		reserved: make(map[string]int64),
+		refusals: make(map[string]Refusal),
+	}
+}
+
+// Reserve 为启动器即将下载的 need 字节预留空间。空间不足时先调用 Reclaim 再重新检查，
+// 仍不足时返回包装 ErrInsufficientSpace 的错误并记录拒绝。成功时返回的 release 必须在下载结束后调用。
+func (g *Guard) Reserve(launcher, version string, need int64) (func(), error) {
+	if need <= 0 {
+		return func() {}, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	err := g.check(launcher, need)
+	if err != nil && g.Reclaim != nil {
+		g.Reclaim(launcher)
+		err = g.check(launcher, need)
+	}
+	if err != nil {
+		prev, seen := g.refusals[launcher]
+		g.refusals[launcher] = Refusal{
+			Launcher: launcher,
+			Version:  version,
+			Reason:   err.Error(),
+			Need:     need,
+			At:       time.Now(),
+			Count:    prev.Count + 1,
+		}
+		g.refused++
+		if !seen {
+			events.Alert(events.KindDiskSpace, launcher, "拒绝下载版本 %s：%v", version, err)
+		}
+		return nil, err
+	}
+	if _, ok := g.refusals[launcher]; ok {
+		delete(g.refusals, launcher)
+		events.Info(events.KindDiskSpace, launcher, "空间已足够，继续下载版本 %s", version)
+	}
+
+	g.reserved[launcher] += need
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			if g.reserved[launcher] -= need; g.reserved[launcher] <= 0 {
+				delete(g.reserved, launcher)
+			}
+		})
+	}, nil
+}
+
+// Check 只检查启动器下载 need 字节是否会超出空间或配额，不预留也不回收，用于试运行
+func (g *Guard) Check(launcher string, need int64) error {
+	if need <= 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.check(launcher, need)
+}
+
+// check 依次检查可用空间、总配额与启动器配额，调用方需持有 mu
+func (g *Guard) check(launcher string, need int64) error {
+	var reserved int64
+	for _, n := range g.reserved {
+		reserved += n
+	}
+	if g.MinFree >= 0 {
+		// 不支持 statfs 的系统上跳过可用空间检查
+		if free, err := FreeSpace(g.base); err == nil {
+			if avail := free - reserved - g.MinFree; need > avail {
+				return fmt.Errorf("%w：需要 %s，可用 %s（其中 %s 已预留给进行中的下载，另需保留 %s）",
+					ErrInsufficientSpace, FormatBytes(need), FormatBytes(free), FormatBytes(reserved), FormatBytes(g.MinFree))
+			}
+		}
+	}
+	if g.Total > 0 {
+		used, err := Usage(g.base)
+		if err != nil {
+			return fmt.Errorf("统计存储目录占用失败: %w", err)
+		}
+		if used+reserved+need > g.Total {
+			return fmt.Errorf("%w：超出存储目录配额 %s（已用 %s，预留 %s，需要 %s）",
+				ErrInsufficientSpace, FormatBytes(g.Total), FormatBytes(used), FormatBytes(reserved), FormatBytes(need))
+		}
+	}
+	if g.LauncherQuota != nil {
+		if limit := g.LauncherQuota(launcher); limit > 0 {
+			used, err := Usage(filepath.Join(g.base, launcher))
+			if err != nil {
+				return fmt.Errorf("统计启动器 %s 的占用失败: %w", launcher, err)
+			}
+			if used+g.reserved[launcher]+need > limit {
+				return fmt.Errorf("%w：超出启动器 %s 的配额 %s（已用 %s，需要 %s）",
+					ErrInsufficientSpace, launcher, FormatBytes(limit), FormatBytes(used), FormatBytes(need))
+			}
+		}
+	}
+	return nil
+}
+
+// Status 返回存储目录与 launchers 中各启动器的占用情况，以及最近的拒绝记录
+func (g *Guard) Status(launchers []string) (Status, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := Status{
+		MinFreeBytes: g.MinFree,
+		QuotaBytes:   g.Total,
+		Launchers:    []LauncherUsage{},
+		Refusals:     []Refusal{},
+		RefusedTotal: g.refused,
+	}
+	if free, err := FreeSpace(g.base); err == nil {
+		st.FreeBytes = &free
+	}
+	used, err := Usage(g.base)
+	if err != nil {
+		return st, err
+	}
+	st.UsedBytes = used
+	for _, n := range g.reserved {
+		st.ReservedBytes += n
+	}
+	for _, name := range launchers {
+		u := LauncherUsage{Name: name, ReservedBytes: g.reserved[name]}
+		if g.LauncherQuota != nil {
+			u.QuotaBytes = g.LauncherQuota(name)
+		}
+		if u.UsedBytes, err = Usage(filepath.Join(g.base, name)); err != nil {
+			return st, err
+		}
+		st.Launchers = append(st.Launchers, u)
+	}
+	for _, r := range g.refusals {
+		st.Refusals = append(st.Refusals, r)
+	}
+	sort.Slice(st.Refusals, func(i, j int) bool { return st.Refusals[i].Launcher < st.Refusals[j].Launcher })
+	return st, nil
+}
+
+// Usage 返回 dir 下全部普通文件的大小之和，同一文件的多个硬链接只统计一次；dir 不存在时返回 0
+func Usage(dir string) (int64, error) {
+	type inode struct{ dev, ino uint64 }
+	seen := make(map[inode]bool)
+	var total int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // 统计过程中被删除
+			}
+			return err
+		}
+		if dev, ino, ok := fileID(fi); ok {
+			if seen[inode{dev, ino}] {
+				return nil
+			}
+			seen[inode{dev, ino}] = true
+		}
+		total += fi.Size()
+		return nil
+	})
+	return total, err
+}
+
+// FormatBytes 以 KB/MB/GB 格式化字节数
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTP"[exp])
+}
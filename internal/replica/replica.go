@@ -0,0 +1,490 @@
+package replica
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lemwood_mirror/internal/downloader"
+	"lemwood_mirror/internal/quota"
+	"lemwood_mirror/internal/scans"
+)
+
+// yankPrefix 为主节点撤回版本时副本自动撤回的原因前缀；
+// 主节点重新提供该版本时只恢复带有此前缀的撤回，不影响副本管理员手动撤回的版本
+const yankPrefix = "主节点已撤回该版本"
+
+// Target 是副本本地的版本索引，由 server.State 实现
+type Target interface {
+	UpdateIndex(launcher, version, infoPath string)
+	SwitchLatest(launcher, version, reason string) error
+	Latest(launcher string) string
+	Published() map[string][]string
+	Yank(launcher, version, reason string) error
+	Unyank(launcher, version string) error
+	Yanked(launcher, version string) (bool, string)
+	DeleteVersion(launcher, version string) error
+	CheckStale(launcher string)
+}
+
+// Status 是副本与主节点的同步状况
+type Status struct {
+	Primary     string    `json:"primary"`
+	Syncing     bool      `json:"syncing"`
+	LastSync    time.Time `json:"last_sync"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	Versions    int       `json:"versions"` // 与主节点一致的版本数
+	Missing     int       `json:"missing"`  // 主节点已发布但副本尚未同步的版本数
+	Bytes       int64     `json:"bytes"`    // 进程启动以来从主节点下载的字节数
+}
+
+// Syncer 定期读取主节点的 /api/status 与 /api/latest，从主节点的 /download/ 下载新版本，
+// 并使本地的最新版本、撤回与删除与主节点保持一致。副本不访问 GitHub。
+type Syncer struct {
+	// Primary 为主节点根地址，不带结尾斜杠
+	Primary string
+	// Base 为本地存储目录
+	Base   string
+	Target Target
+	// Downloader 为本地下载队列，资源经由它下载、校验并发布
+	Downloader *downloader.Downloader
+	// 生成本节点资源下载地址的参数，与镜像 GitHub 时相同
+	ServerAddress   string
+	ServerPort      int
+	DownloadURLBase string
+	// Timeout 为单个启动器一次同步的超时时间，0 表示不限制
+	Timeout time.Duration
+
+	client *http.Client
+
+	mu      sync.Mutex
+	status  Status
+	running bool
+	again   bool // 同步进行中收到新的同步请求，结束后再同步一次
+}
+
+// New 创建从 primary 同步到 base 的副本
+func New(primary, base string, target Target, d *downloader.Downloader) *Syncer {
+	primary = strings.TrimRight(primary, "/")
+	return &Syncer{
+		Primary:    primary,
+		Base:       base,
+		Target:     target,
+		Downloader: d,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		status:     Status{Primary: primary},
+	}
+}
+
+// Start 立即同步一次，之后每隔 interval 同步
+func (s *Syncer) Start(interval time.Duration) {
+	go func() {
+		s.Sync()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.Sync()
+		}
+	}()
+}
+
+// Status 返回同步状况
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status
+	st.Syncing = s.running
+	return st
+}
+
+// Sync 与主节点同步一次。同步已在进行中时不等待，而是在其结束后再同步一次，
+// 避免进行中的同步错过主节点刚发布的版本。
+func (s *Syncer) Sync() {
+	s.mu.Lock()
+	if s.running {
+		s.again = true
+		s.mu.Unlock()
+		log.Printf("副本同步已在进行中，结束后再同步一次")
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	for {
+		err := s.syncOnce(context.Background())
+		s.mu.Lock()
+		s.status.LastSync = time.Now()
+		if err != nil {
+			s.status.LastError = err.Error()
+		} else {
+			s.status.LastError = ""
+			s.status.LastSuccess = s.status.LastSync
+		}
+		again := s.again
+		s.again = false
+		s.running = again
+		s.mu.Unlock()
+		if !again {
+			return
+		}
+	}
+}
+
+// snapshot 是主节点某一时刻对外提供的版本
+type snapshot struct {
+	versions map[string][]downloader.ReleaseInfo // /api/status：不含撤回与待发布的版本
+	latest   map[string]string
+	pending  map[string]map[string]bool
+}
+
+func (s *Syncer) syncOnce(ctx context.Context) error {
+	snap, err := s.fetch(ctx)
+	if err != nil {
+		log.Printf("读取主节点 %s 的版本失败: %v", s.Primary, err)
+		return err
+	}
+
+	// 主节点与本地的启动器取并集，主节点上已不存在的启动器也要同步撤回与删除
+	names := make(map[string]bool)
+	for l := range snap.versions {
+		// 启动器名会拼接到存储路径中，主节点返回的无效名称直接忽略
+		if err := downloader.ValidName(l); err != nil {
+			log.Printf("忽略主节点返回的启动器 %q: %v", l, err)
+			continue
+		}
+		names[l] = true
+	}
+	local := s.Target.Published()
+	for l := range local {
+		names[l] = true
+	}
+
+	type result struct {
+		synced, missing int
+		bytes           int64
+		err             error
+	}
+	results := make(map[string]*result, len(names))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for l := range names {
+		wg.Add(1)
+		go func(l string) {
+			defer wg.Done()
+			r := &result{}
+			r.synced, r.missing, r.bytes, r.err = s.syncLauncher(ctx, l, snap, local[l])
+			mu.Lock()
+			results[l] = r
+			mu.Unlock()
+		}(l)
+	}
+	wg.Wait()
+
+	var failed []string
+	synced, missing := 0, 0
+	var fetched int64
+	for l, r := range results {
+		synced += r.synced
+		missing += r.missing
+		fetched += r.bytes
+		if r.err != nil {
+			failed = append(failed, l)
+		}
+	}
+	s.mu.Lock()
+	s.status.Versions = synced
+	s.status.Missing = missing
+	s.status.Bytes += fetched
+	s.mu.Unlock()
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%d 个启动器同步失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// syncLauncher 同步单个启动器：下载主节点上的新版本与被替换的资源，恢复或撤回版本，删除主节点已删除的版本，
+// 最后切换到主节点的最新版本。返回与主节点一致的版本数、尚未同步的版本数与下载的字节数。
+func (s *Syncer) syncLauncher(ctx context.Context, launcher string, snap *snapshot, localVersions []string) (synced, missing int, fetched int64, err error) {
+	rec := scans.Begin(launcher)
+	outcome := scans.OutcomeUpToDate
+	latest := snap.latest[launcher]
+	defer func() {
+		if err != nil && outcome == scans.OutcomeUpToDate {
+			outcome = scans.OutcomeFailed
+		}
+		rec.Finish(outcome, latest, fetched, err)
+		s.Target.CheckStale(launcher)
+	}()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	have := make(map[string]bool, len(localVersions))
+	for _, v := range localVersions {
+		have[v] = true
+	}
+	// 最新版本先下载，旧版本随后补齐
+	remote := append([]downloader.ReleaseInfo(nil), snap.versions[launcher]...)
+	sort.SliceStable(remote, func(i, j int) bool { return remote[i].TagName == latest && remote[j].TagName != latest })
+	listed := make(map[string]bool, len(remote))
+	for _, info := range remote {
+		rel, verr := release(s.Primary, launcher, info)
+		if verr != nil {
+			log.Printf("%s: 忽略主节点返回的版本 %q: %v", launcher, info.TagName, verr)
+			continue
+		}
+		version := rel.Version()
+		listed[version] = true
+		if len(rel.Assets) == 0 {
+			// 主节点读取不到 index.json 时只列出版本号，不能据此发布空版本
+			log.Printf("%s: 主节点没有返回版本 %s 的资源，跳过", launcher, version)
+			missing++
+			continue
+		}
+
+		if yanked, reason := s.Target.Yanked(launcher, version); yanked {
+			if !strings.HasPrefix(reason, yankPrefix) {
+				// 副本管理员手动撤回的版本保持撤回
+				synced++
+				continue
+			}
+			if uerr := s.Target.Unyank(launcher, version); uerr != nil {
+				log.Printf("%s: 恢复版本 %s 失败: %v", launcher, version, uerr)
+			} else {
+				log.Printf("%s: 主节点已恢复版本 %s，副本同步恢复", launcher, version)
+				outcome = updated(outcome)
+			}
+		}
+		if have[version] && !downloader.AssetsChanged(s.Base, launcher, rel) {
+			synced++
+			continue
+		}
+
+		result, derr := s.Downloader.DownloadLatest(ctx, launcher, s.Base, rel, s.ServerAddress, s.ServerPort, s.DownloadURLBase, version == latest, false)
+		if result != nil {
+			fetched += result.BytesFetched()
+		}
+		if derr != nil {
+			missing++
+			log.Printf("%s: 从主节点同步版本 %s 失败: %v", launcher, version, derr)
+			switch {
+			case errors.Is(derr, downloader.ErrDeferred):
+				if outcome == scans.OutcomeUpToDate {
+					outcome = scans.OutcomeDeferred
+				}
+			case errors.Is(derr, quota.ErrInsufficientSpace):
+				outcome, err = scans.OutcomeNoSpace, derr
+			default:
+				outcome, err = scans.OutcomeFailed, fmt.Errorf("同步版本 %s 失败: %w", version, derr)
+			}
+			if ctx.Err() != nil {
+				return synced, missing, fetched, err
+			}
+			continue
+		}
+		s.Target.UpdateIndex(launcher, version, result.InfoPath)
+		have[version] = true
+		synced++
+		outcome = updated(outcome)
+		log.Printf("%s: 已从主节点同步版本 %s", launcher, version)
+	}
+
+	// 本地有但主节点不再列出的版本：待发布的保留，被撤回的同步撤回，已删除的同步删除
+	for _, version := range localVersions {
+		if listed[version] || snap.pending[launcher][version] {
+			continue
+		}
+		gone, reason, perr := s.probe(ctx, launcher, version)
+		if perr != nil {
+			log.Printf("%s: 检查主节点上的版本 %s 失败: %v", launcher, version, perr)
+			continue
+		}
+		switch {
+		case gone:
+			if yanked, _ := s.Target.Yanked(launcher, version); yanked {
+				continue
+			}
+			if reason == "" {
+				reason = yankPrefix
+			} else {
+				reason = yankPrefix + "：" + reason
+			}
+			if yerr := s.Target.Yank(launcher, version, reason); yerr != nil {
+				log.Printf("%s: 撤回版本 %s 失败: %v", launcher, version, yerr)
+				continue
+			}
+			log.Printf("%s: 主节点已撤回版本 %s，副本同步撤回", launcher, version)
+			outcome = updated(outcome)
+		default:
+			if derr := s.Target.DeleteVersion(launcher, version); derr != nil {
+				log.Printf("%s: 删除版本 %s 失败: %v", launcher, version, derr)
+				continue
+			}
+			log.Printf("%s: 主节点已删除版本 %s，副本同步删除", launcher, version)
+			outcome = updated(outcome)
+		}
+	}
+
+	// 即使本地的最新版本已经相同也切换一次，确保 index.json 中的 latest 标记一致；没有变化时不会改写文件
+	if yanked, _ := s.Target.Yanked(launcher, latest); latest != "" && have[latest] && !yanked {
+		previous := s.Target.Latest(launcher)
+		if serr := s.Target.SwitchLatest(launcher, latest, "与主节点同步"); serr != nil {
+			log.Printf("%s: 切换最新版本失败: %v", launcher, serr)
+			if err == nil {
+				err = fmt.Errorf("切换最新版本失败: %w", serr)
+			}
+		} else if previous != latest {
+			outcome = updated(outcome)
+		}
+	}
+	return synced, missing, fetched, err
+}
+
+// updated 在没有更严重的结果时把同步结果记为已更新
+func updated(outcome string) string {
+	if outcome == scans.OutcomeUpToDate || outcome == scans.OutcomeDeferred {
+		return scans.OutcomeUpdated
+	}
+	return outcome
+}
+
+// release 将主节点 /api/status 中的版本转换为从主节点下载的 Release。
+// 资源的摘要使用主节点计算的 SHA-256，下载后逐一核对；主节点不再重定向，避免副本被转发到其他节点。
+// 版本号或资源名可能逃出存储目录时返回错误。
+func release(primary, launcher string, info downloader.ReleaseInfo) (*downloader.Release, error) {
+	rel := &downloader.Release{
+		TagName:     info.TagName,
+		Name:        info.Name,
+		PublishedAt: info.PublishedAt,
+		Prerelease:  info.Prerelease,
+		Body:        info.Body,
+		Author:      info.Author,
+		HTMLURL:     info.HTMLURL,
+	}
+	version := rel.Version()
+	if err := downloader.ValidName(version); err != nil {
+		return nil, err
+	}
+	for _, a := range info.Assets {
+		if err := downloader.ValidName(a.Name); err != nil {
+			return nil, fmt.Errorf("资源名无效: %w", err)
+		}
+		digest := a.UpstreamDigest
+		if a.SHA256 != "" {
+			digest = "sha256:" + a.SHA256
+		}
+		rel.Assets = append(rel.Assets, downloader.Asset{
+			ID:            a.UpstreamID,
+			Name:          a.Name,
+			URL:           downloadURL(primary, launcher, version, a.Name),
+			Size:          a.Size,
+			ContentType:   a.ContentType,
+			UpdatedAt:     a.UpdatedAt,
+			DownloadCount: a.UpstreamDownloadCount,
+			Digest:        digest,
+		})
+	}
+	return rel, nil
+}
+
+func downloadURL(primary, launcher, version, name string) string {
+	return fmt.Sprintf("%s/download/%s/%s/%s?noredirect=1", primary,
+		url.PathEscape(launcher), url.PathEscape(version), url.PathEscape(name))
+}
+
+// fetch 读取主节点的已发布版本、最新版本与待发布版本
+func (s *Syncer) fetch(ctx context.Context) (*snapshot, error) {
+	snap := &snapshot{pending: make(map[string]map[string]bool)}
+	if err := s.getJSON(ctx, "/api/status", &snap.versions); err != nil {
+		return nil, err
+	}
+	if err := s.getJSON(ctx, "/api/latest", &snap.latest); err != nil {
+		return nil, err
+	}
+	var pending map[string][]struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := s.getJSON(ctx, "/api/pending", &pending); err != nil {
+		return nil, err
+	}
+	for l, list := range pending {
+		snap.pending[l] = make(map[string]bool, len(list))
+		for _, p := range list {
+			snap.pending[l][p.TagName] = true
+		}
+	}
+	return snap, nil
+}
+
+func (s *Syncer) getJSON(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Primary+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// probe 检查主节点上不再列出的版本：返回 410 时 gone 为 true 并返回撤回原因，返回 404 时视为已删除。
+// 其他状态（包括版本仍可下载）返回错误，本地保持不变。
+// 先用 HEAD 请求，不下载 index.json；只有版本被撤回时再用 GET 读取响应中的撤回原因。
+func (s *Syncer) probe(ctx context.Context, launcher, version string) (gone bool, reason string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	target := downloadURL(s.Primary, launcher, version, "index.json")
+	status, _, err := s.request(ctx, http.MethodHead, target)
+	if err != nil {
+		return false, "", err
+	}
+	switch status {
+	case http.StatusGone:
+		// 撤回的版本只返回原因，不提供文件
+		if status, body, err := s.request(ctx, http.MethodGet, target); err == nil && status == http.StatusGone {
+			reason = body
+		}
+		return true, reason, nil
+	case http.StatusNotFound:
+		return false, "", nil
+	}
+	return false, "", fmt.Errorf("主节点返回 %d %s", status, http.StatusText(status))
+}
+
+// request 发送请求并返回状态码与响应体的前 4KB
+func (s *Syncer) request(ctx context.Context, method, target string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,249 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Policy 描述重试策略：指数退避加随机抖动，区分永久错误与暂时错误
+type Policy struct {
+	MaxAttempts   int           // 最多尝试次数（含第一次）
+	BaseDelay     time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxDelay      time.Duration // 单次退避的上限
+	Jitter        float64       // 抖动比例，0.5 表示在 [0.5, 1.0] 倍之间随机
+	MaxRetryAfter time.Duration // 服务端要求等待（Retry-After）超过该值时放弃重试
+}
+
+// Default 返回默认策略
+func Default() Policy {
+	return Policy{
+		MaxAttempts:   4,
+		BaseDelay:     2 * time.Second,
+		MaxDelay:      time.Minute,
+		Jitter:        0.5,
+		MaxRetryAfter: 15 * time.Minute,
+	}
+}
+
+// normalize 用默认值补全未设置的字段
+func (p Policy) normalize() Policy {
+	def := Default()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = def.Jitter
+	}
+	if p.MaxRetryAfter <= 0 {
+		p.MaxRetryAfter = def.MaxRetryAfter
+	}
+	return p
+}
+
+// Backoff 返回第 retry 次重试（从 1 开始）前的等待时间
+func (p Policy) Backoff(retry int) time.Duration {
+	p = p.normalize()
+	d := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	if d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	d *= 1 - p.Jitter*rand.Float64()
+	return time.Duration(d)
+}
+
+// Do 执行 op，遇到暂时错误时按策略重试，遇到永久错误、上下文取消或重试预算耗尽时立即返回。
+// attempt 从 1 开始。
+func (p Policy) Do(ctx context.Context, op func(attempt int) error) error {
+	p = p.normalize()
+	budget := BudgetFrom(ctx)
+	for attempt := 1; ; attempt++ {
+		err := op(attempt)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		retryable, retryAfter := Classify(err)
+		if !retryable || attempt >= p.MaxAttempts {
+			return err
+		}
+		if retryAfter > p.MaxRetryAfter {
+			return fmt.Errorf("%w（服务端要求等待 %s，超过上限）", err, retryAfter.Round(time.Second))
+		}
+		if !budget.Take() {
+			return fmt.Errorf("%w（本次扫描的重试预算已用完）", err)
+		}
+		wait := p.Backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// HTTPError 表示上游返回了非预期的状态码
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration // 来自 Retry-After 等响应头，0 表示未指定
+	Err        error         // 原始错误，可为空
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("状态码 %d", e.StatusCode)
+}
+
+func (e *HTTPError) Unwrap() error { return e.Err }
+
+// FromResponse 根据响应状态码与 Retry-After 头构造 HTTPError
+func FromResponse(resp *http.Response, err error) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 将错误标记为永久错误，不再重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent 判断错误是否为永久错误
+func IsPermanent(err error) bool {
+	retryable, _ := Classify(err)
+	return err != nil && !retryable
+}
+
+// Classify 判断错误是否值得重试，并返回服务端要求的最短等待时间。
+// 404、410 等客户端错误为永久错误；5xx、408、429、连接重置与超时为暂时错误。
+func Classify(err error) (retryable bool, retryAfter time.Duration) {
+	if err == nil {
+		return false, 0
+	}
+	var pe *permanentError
+	if errors.As(err, &pe) {
+		return false, 0
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		switch {
+		case he.StatusCode == http.StatusRequestTimeout, he.StatusCode == http.StatusTooEarly,
+			he.StatusCode == http.StatusTooManyRequests, he.StatusCode >= 500:
+			return true, he.RetryAfter
+		case he.StatusCode == http.StatusForbidden && he.RetryAfter > 0:
+			// GitHub 的速率限制以 403 返回，并带有等待时间
+			return true, he.RetryAfter
+		default:
+			return false, 0
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true, 0
+	}
+	// 其他网络层错误（DNS、TLS 握手等）通常是暂时的；磁盘写入失败之类的本地错误不重试
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true, 0
+	}
+	return false, 0
+}
+
+// ParseRetryAfter 解析 Retry-After 头，支持秒数与 HTTP 日期两种形式
+func ParseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Budget 限制一次扫描内的重试总次数，nil 表示不限制
+type Budget struct {
+	remaining int64
+}
+
+// NewBudget 创建重试预算，n <= 0 时返回 nil（不限制）
+func NewBudget(n int) *Budget {
+	if n <= 0 {
+		return nil
+	}
+	return &Budget{remaining: int64(n)}
+}
+
+// Take 消耗一次重试，预算用完时返回 false
+func (b *Budget) Take() bool {
+	if b == nil {
+		return true
+	}
+	return atomic.AddInt64(&b.remaining, -1) >= 0
+}
+
+// Remaining 返回剩余的重试次数
+func (b *Budget) Remaining() int {
+	if b == nil {
+		return -1
+	}
+	if n := atomic.LoadInt64(&b.remaining); n > 0 {
+		return int(n)
+	}
+	return 0
+}
+
+type budgetKey struct{}
+
+// WithBudget 将重试预算附加到上下文
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// BudgetFrom 取出上下文中的重试预算
+func BudgetFrom(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
@@ -0,0 +1,206 @@
+package scans
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"lemwood_mirror/internal/db"
+)
+
+// 扫描结果
+const (
+	OutcomeRunning     = "running"     // 扫描进行中
+	OutcomeUpdated     = "updated"     // 镜像了新版本
+	OutcomeUpToDate    = "up_to_date"  // 上游没有新版本
+	OutcomeDeferred    = "deferred"    // 资源推迟到下载时间窗口内下载
+	OutcomeSkipped     = "skipped"     // 上游版本已被管理员撤回等原因，未镜像
+	OutcomeFailed      = "failed"      // 扫描失败
+	OutcomeNoSpace     = "no_space"    // 磁盘空间或配额不足，拒绝下载新版本
+	OutcomeInterrupted = "interrupted" // 进程在扫描过程中退出
+)
+
+// Scan 是一次扫描的记录
+type Scan struct {
+	ID              int64      `json:"id"`
+	Launcher        string     `json:"launcher"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	UpstreamVersion string     `json:"upstream_version,omitempty"`
+	Outcome         string     `json:"outcome"`
+	Error           string     `json:"error,omitempty"`
+	Bytes           int64      `json:"bytes"`
+}
+
+// Summary 汇总启动器最近的扫描情况
+type Summary struct {
+	LastScan            *Scan      `json:"last_scan,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	// UpstreamVersion 为最近一次扫描看到的上游版本，UpstreamSeenAt 为首次看到它的时间
+	UpstreamVersion string     `json:"upstream_version,omitempty"`
+	UpstreamSeenAt  *time.Time `json:"upstream_seen_at,omitempty"`
+}
+
+// Begin 记录一次扫描开始。数据库不可用时返回的记录仍可调用 Finish。
+func Begin(launcher string) *Scan {
+	sc := &Scan{Launcher: launcher, StartedAt: time.Now(), Outcome: OutcomeRunning}
+	if db.DB == nil {
+		return sc
+	}
+	res, err := db.DB.Exec(`INSERT INTO scans (launcher, started_at, outcome) VALUES (?, ?, ?)`,
+		launcher, sc.StartedAt, sc.Outcome)
+	if err != nil {
+		log.Printf("记录扫描失败: %v", err)
+		return sc
+	}
+	sc.ID, _ = res.LastInsertId()
+	return sc
+}
+
+// Finish 记录扫描结束。err 非空时保存错误信息。
+func (sc *Scan) Finish(outcome, upstreamVersion string, bytes int64, err error) {
+	now := time.Now()
+	sc.FinishedAt = &now
+	sc.Outcome = outcome
+	sc.UpstreamVersion = upstreamVersion
+	sc.Bytes = bytes
+	if err != nil {
+		sc.Error = err.Error()
+	}
+	if db.DB == nil || sc.ID == 0 {
+		return
+	}
+	if _, err := db.DB.Exec(`UPDATE scans SET finished_at = ?, upstream_version = ?, outcome = ?, error = ?, bytes = ? WHERE id = ?`,
+		now, sc.UpstreamVersion, sc.Outcome, sc.Error, sc.Bytes, sc.ID); err != nil {
+		log.Printf("记录扫描结果失败: %v", err)
+	}
+}
+
+// MarkInterrupted 将上次进程退出时仍在进行的扫描标记为中断，应在启动时调用
+func MarkInterrupted() error {
+	if db.DB == nil {
+		return nil
+	}
+	_, err := db.DB.Exec(`UPDATE scans SET outcome = ?, finished_at = ? WHERE outcome = ?`,
+		OutcomeInterrupted, time.Now(), OutcomeRunning)
+	return err
+}
+
+// History 按时间倒序返回扫描记录，launcher 为空时返回全部启动器
+func History(launcher string, limit int) ([]Scan, error) {
+	if db.DB == nil {
+		return nil, errors.New("数据库未初始化")
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT id, launcher, started_at, finished_at, upstream_version, outcome, error, bytes FROM scans`
+	var args []any
+	if launcher != "" {
+		query += ` WHERE launcher = ?`
+		args = append(args, launcher)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Scan{}
+	for rows.Next() {
+		sc, err := scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sc)
+	}
+	return list, rows.Err()
+}
+
+func scanRow(rows *sql.Rows) (Scan, error) {
+	var sc Scan
+	var finished sql.NullTime
+	var upstream, errText sql.NullString
+	var bytes sql.NullInt64
+	if err := rows.Scan(&sc.ID, &sc.Launcher, &sc.StartedAt, &finished, &upstream, &sc.Outcome, &errText, &bytes); err != nil {
+		return sc, err
+	}
+	if finished.Valid {
+		t := finished.Time
+		sc.FinishedAt = &t
+	}
+	sc.UpstreamVersion = upstream.String
+	sc.Error = errText.String
+	sc.Bytes = bytes.Int64
+	return sc, nil
+}
+
+// succeeded 判断扫描是否成功访问了上游
+func succeeded(outcome string) bool {
+	switch outcome {
+	case OutcomeUpdated, OutcomeUpToDate, OutcomeDeferred, OutcomeSkipped, OutcomeNoSpace:
+		return true
+	}
+	return false
+}
+
+// Summarize 汇总启动器最近的扫描：最后一次扫描、最后一次成功、连续失败次数与最近看到的上游版本。
+// 连续失败从最近一次扫描往前数，遇到成功的扫描为止，进行中与中断的扫描不计入。
+func Summarize(launcher string) (Summary, error) {
+	var sum Summary
+	if db.DB == nil {
+		return sum, errors.New("数据库未初始化")
+	}
+	rows, err := db.DB.Query(`SELECT id, launcher, started_at, finished_at, upstream_version, outcome, error, bytes
+        FROM scans WHERE launcher = ? ORDER BY id DESC LIMIT 200`, launcher)
+	if err != nil {
+		return sum, err
+	}
+	defer rows.Close()
+	counting := true
+	for rows.Next() {
+		sc, err := scanRow(rows)
+		if err != nil {
+			return sum, err
+		}
+		if sum.LastScan == nil {
+			last := sc
+			sum.LastScan = &last
+		}
+		if sum.UpstreamVersion == "" && sc.UpstreamVersion != "" {
+			sum.UpstreamVersion = sc.UpstreamVersion
+		}
+		switch {
+		case succeeded(sc.Outcome):
+			counting = false
+			if sum.LastSuccess == nil {
+				t := sc.StartedAt
+				if sc.FinishedAt != nil {
+					t = *sc.FinishedAt
+				}
+				sum.LastSuccess = &t
+			}
+		case sc.Outcome == OutcomeFailed && counting:
+			sum.ConsecutiveFailures++
+		}
+		if !counting && sum.LastSuccess != nil && sum.UpstreamVersion != "" {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return sum, err
+	}
+	rows.Close()
+
+	if sum.UpstreamVersion != "" {
+		var seen time.Time
+		if err := db.DB.QueryRow(`SELECT started_at FROM scans WHERE launcher = ? AND upstream_version = ? ORDER BY id LIMIT 1`,
+			launcher, sum.UpstreamVersion).Scan(&seen); err == nil {
+			sum.UpstreamSeenAt = &seen
+		}
+	}
+	return sum, nil
+}
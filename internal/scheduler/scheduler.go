@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// hotfixWindow 为上游发布新版本后的密集检查期，热修复版本通常在这段时间内发布
+const hotfixWindow = 48 * time.Hour
+
+// Spec 描述单个启动器的检查计划。Cron 与 Interval 二选一；
+// Adaptive 非空时按上游的发布节奏调整检查间隔，Interval 作为没有发布记录时的间隔。
+type Spec struct {
+	Cron     string
+	Interval time.Duration
+	Jitter   time.Duration // 每次检查前随机延迟 [0, Jitter)，避免同时访问上游
+	Adaptive *Adaptive
+}
+
+// Adaptive 为自适应检查间隔的上下限
+type Adaptive struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Validate 检查计划是否有效
+func (s Spec) Validate() error {
+	if s.Cron != "" {
+		if s.Interval > 0 || s.Adaptive != nil {
+			return errors.New("cron 表达式不能与检查间隔或自适应检查同时使用")
+		}
+		if _, err := cron.ParseStandard(s.Cron); err != nil {
+			return fmt.Errorf("无效的 cron 表达式 %q: %w", s.Cron, err)
+		}
+		return nil
+	}
+	if s.Interval <= 0 && s.Adaptive == nil {
+		return errors.New("需要 cron 表达式、检查间隔或自适应检查")
+	}
+	if s.Adaptive != nil && (s.Adaptive.Min <= 0 || s.Adaptive.Max < s.Adaptive.Min) {
+		return errors.New("自适应检查的间隔上下限无效")
+	}
+	return nil
+}
+
+// Scheduler 为每个启动器维护独立的检查计划，一个启动器的扫描不会阻塞其他启动器
+type Scheduler struct {
+	// Run 执行一次检查，返回后才计算下一次检查时间
+	Run func(name string)
+	// Releases 返回启动器已知版本的发布时间，用于自适应检查
+	Releases func(name string) []time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+type job struct {
+	name string
+	spec Spec
+	cron cron.Schedule
+	stop chan struct{}
+}
+
+// New 创建调度器
+func New(run func(name string), releases func(name string) []time.Time) *Scheduler {
+	return &Scheduler{Run: run, Releases: releases, jobs: make(map[string]*job)}
+}
+
+// Set 设置启动器的检查计划，替换已有的计划
+func (s *Scheduler) Set(name string, spec Spec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	j := &job{name: name, spec: spec, stop: make(chan struct{})}
+	if spec.Cron != "" {
+		j.cron, _ = cron.ParseStandard(spec.Cron)
+	}
+	s.mu.Lock()
+	if old, ok := s.jobs[name]; ok {
+		close(old.stop)
+	}
+	s.jobs[name] = j
+	s.mu.Unlock()
+	go s.loop(j)
+	return nil
+}
+
+// Remove 取消启动器的检查计划，正在进行的检查不受影响
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[name]; ok {
+		close(j.stop)
+		delete(s.jobs, name)
+	}
+}
+
+// Stop 取消全部检查计划
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, j := range s.jobs {
+		close(j.stop)
+		delete(s.jobs, name)
+	}
+}
+
+func (s *Scheduler) loop(j *job) {
+	for {
+		next := s.next(j, time.Now())
+		t := time.NewTimer(time.Until(next))
+		select {
+		case <-j.stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		// 计划可能在等待期间被替换或取消
+		select {
+		case <-j.stop:
+			return
+		default:
+		}
+		s.Run(j.name)
+	}
+}
+
+// next 计算下一次检查时间
+func (s *Scheduler) next(j *job, now time.Time) time.Time {
+	var at time.Time
+	switch {
+	case j.cron != nil:
+		at = j.cron.Next(now)
+	case j.spec.Adaptive != nil:
+		var releases []time.Time
+		if s.Releases != nil {
+			releases = s.Releases(j.name)
+		}
+		interval := AdaptiveInterval(now, releases, *j.spec.Adaptive, j.spec.Interval)
+		at = now.Add(interval)
+		log.Printf("%s: 自适应检查间隔为 %s", j.name, interval.Round(time.Minute))
+	default:
+		at = now.Add(j.spec.Interval)
+	}
+	if j.spec.Jitter > 0 {
+		at = at.Add(time.Duration(rand.Int63n(int64(j.spec.Jitter))))
+	}
+	return at
+}
+
+// AdaptiveInterval 根据发布节奏计算检查间隔：
+// 最近一次发布在 48 小时内时使用下限，便于及时镜像热修复版本；
+// 否则取“距上次发布的时长”与“发布间隔中位数”中较小者的 1/24，限制在上下限之间。
+// 长期不发布的仓库间隔逐渐增大，但不会超过其惯常发布间隔对应的值。
+// 没有发布记录时使用 fallback（为 0 时使用上限）。
+func AdaptiveInterval(now time.Time, releases []time.Time, a Adaptive, fallback time.Duration) time.Duration {
+	var times []time.Time
+	for _, t := range releases {
+		if !t.IsZero() && !t.After(now) {
+			times = append(times, t)
+		}
+	}
+	if len(times) == 0 {
+		if fallback > 0 {
+			return clamp(fallback, a)
+		}
+		return a.Max
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	since := now.Sub(times[len(times)-1])
+	if since < hotfixWindow {
+		return a.Min
+	}
+	quiet := since
+	if len(times) >= 2 {
+		gaps := make([]time.Duration, 0, len(times)-1)
+		for i := 1; i < len(times); i++ {
+			gaps = append(gaps, times[i].Sub(times[i-1]))
+		}
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		if median := gaps[len(gaps)/2]; median > 0 && median < quiet {
+			quiet = median
+		}
+	}
+	return clamp(quiet/24, a)
+}
+
+func clamp(d time.Duration, a Adaptive) time.Duration {
+	if d < a.Min {
+		return a.Min
+	}
+	if d > a.Max {
+		return a.Max
+	}
+	return d
+}
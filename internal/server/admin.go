@@ -0,0 +1,378 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"lemwood_mirror/internal/db"
+)
+
+// Override 是管理员对单个版本的人工干预
+type Override struct {
+	Launcher   string    `json:"launcher"`
+	Version    string    `json:"version"`
+	Yanked     bool      `json:"yanked"`
+	YankReason string    `json:"yank_reason,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// adminRequest 是管理接口的请求体
+type adminRequest struct {
+	Launcher string `json:"launcher"`
+	Version  string `json:"version"`
+	Reason   string `json:"reason"`
+	Note     string `json:"note"`
+}
+
+// LoadOverrides 从数据库加载固定版本、撤回、备注与待发布版本，需要在 InitFromDisk 之前调用
+func (s *State) LoadOverrides() error {
+	if db.DB == nil {
+		return nil
+	}
+	rows, err := db.DB.Query(`SELECT launcher, version, yanked, yank_reason, note, updated_at FROM version_overrides`)
+	if err != nil {
+		return fmt.Errorf("读取版本干预失败: %w", err)
+	}
+	defer rows.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for rows.Next() {
+		var o Override
+		if err := rows.Scan(&o.Launcher, &o.Version, &o.Yanked, &o.YankReason, &o.Note, &o.UpdatedAt); err != nil {
+			return fmt.Errorf("读取版本干预失败: %w", err)
+		}
+		s.setOverrideLocked(o)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	pins, err := db.DB.Query(`SELECT launcher, version FROM latest_pins`)
+	if err != nil {
+		return fmt.Errorf("读取固定版本失败: %w", err)
+	}
+	defer pins.Close()
+	for pins.Next() {
+		var launcher, version string
+		if err := pins.Scan(&launcher, &version); err != nil {
+			return fmt.Errorf("读取固定版本失败: %w", err)
+		}
+		s.pins[launcher] = version
+	}
+	if err := pins.Err(); err != nil {
+		return err
+	}
+	return s.loadPending()
+}
+
+func (s *State) setOverrideLocked(o Override) {
+	if s.overrides[o.Launcher] == nil {
+		s.overrides[o.Launcher] = make(map[string]Override)
+	}
+	if !o.Yanked && o.Note == "" {
+		delete(s.overrides[o.Launcher], o.Version)
+		return
+	}
+	s.overrides[o.Launcher][o.Version] = o
+}
+
+// override 返回版本的人工干预，调用方需持有 mu
+func (s *State) override(launcher, version string) (Override, bool) {
+	o, ok := s.overrides[launcher][version]
+	return o, ok
+}
+
+// Yanked 判断版本是否已被撤回，返回撤回原因
+func (s *State) Yanked(launcher, version string) (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.override(launcher, version)
+	return ok && o.Yanked, o.YankReason
+}
+
+// Pinned 返回启动器被管理员固定的最新版本，未固定时返回空字符串
+func (s *State) Pinned(launcher string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pins[launcher]
+}
+
+func (s *State) saveOverride(o Override) error {
+	o.UpdatedAt = time.Now()
+	if db.DB != nil {
+		var err error
+		if !o.Yanked && o.Note == "" {
+			_, err = db.DB.Exec(`DELETE FROM version_overrides WHERE launcher = ? AND version = ?`, o.Launcher, o.Version)
+		} else {
+			_, err = db.DB.Exec(`INSERT INTO version_overrides (launcher, version, yanked, yank_reason, note, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+                ON CONFLICT(launcher, version) DO UPDATE SET yanked = excluded.yanked, yank_reason = excluded.yank_reason, note = excluded.note, updated_at = excluded.updated_at`,
+				o.Launcher, o.Version, o.Yanked, o.YankReason, o.Note, o.UpdatedAt)
+		}
+		if err != nil {
+			return fmt.Errorf("保存版本干预失败: %w", err)
+		}
+	}
+	s.mu.Lock()
+	s.setOverrideLocked(o)
+	s.feedGen++
+	s.mu.Unlock()
+	return nil
+}
+
+// currentOverride 返回版本现有的干预记录（没有时返回空记录）
+func (s *State) currentOverride(launcher, version string) Override {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if o, ok := s.override(launcher, version); ok {
+		return o
+	}
+	return Override{Launcher: launcher, Version: version}
+}
+
+var (
+	errUnknownVersion = errors.New("不存在")
+	errYanked         = errors.New("已被撤回")
+)
+
+// hasVersion 判断版本是否在索引中
+func (s *State) hasVersion(launcher, version string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.index[launcher][version]
+	return ok
+}
+
+// Pin 将启动器的最新版本固定为 version，扫描不会再自动切换。待发布的版本被固定时视为已批准。
+func (s *State) Pin(launcher, version string) error {
+	if err := s.SwitchLatest(launcher, version, "管理员固定"); err != nil {
+		return err
+	}
+	if err := s.clearPending(launcher, version); err != nil {
+		return err
+	}
+	if db.DB != nil {
+		if _, err := db.DB.Exec(`INSERT INTO latest_pins (launcher, version, updated_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+            ON CONFLICT(launcher) DO UPDATE SET version = excluded.version, updated_at = CURRENT_TIMESTAMP`, launcher, version); err != nil {
+			return fmt.Errorf("保存固定版本失败: %w", err)
+		}
+	}
+	s.mu.Lock()
+	s.pins[launcher] = version
+	s.mu.Unlock()
+	return nil
+}
+
+// Unpin 取消固定并立即切换到排序最新的可用版本，之后扫描恢复自动切换
+func (s *State) Unpin(launcher string) error {
+	if db.DB != nil {
+		if _, err := db.DB.Exec(`DELETE FROM latest_pins WHERE launcher = ?`, launcher); err != nil {
+			return fmt.Errorf("取消固定版本失败: %w", err)
+		}
+	}
+	// 固定期间扫描不会切换最新版本，取消固定后切换到排序最新的可用版本，不必等上游发布更新的版本
+	s.mu.Lock()
+	delete(s.pins, launcher)
+	next := s.pickNewest(launcher, s.index[launcher])
+	current := s.latest[launcher]
+	s.mu.Unlock()
+	if next != "" && next != current {
+		return s.SwitchLatest(launcher, next, "管理员取消固定")
+	}
+	return nil
+}
+
+// Yank 撤回版本：API 中隐藏该版本，下载返回 410。
+// 如果它是当前最新版本，最新版本切换到其余版本中最新的一个；固定在该版本上的设置会被取消，待发布的版本不再发布。
+func (s *State) Yank(launcher, version, reason string) error {
+	if !s.hasVersion(launcher, version) {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errUnknownVersion)
+	}
+	if reason == "" {
+		reason = "该版本已被撤回"
+	}
+	o := s.currentOverride(launcher, version)
+	o.Yanked = true
+	o.YankReason = reason
+	if err := s.saveOverride(o); err != nil {
+		return err
+	}
+	if s.Pinned(launcher) == version {
+		if err := s.Unpin(launcher); err != nil {
+			return err
+		}
+	}
+	if err := s.clearPending(launcher, version); err != nil {
+		return err
+	}
+	return s.replaceLatest(launcher, version, "撤回 "+version)
+}
+
+// Unyank 恢复被撤回的版本，未固定的启动器随后切换到排序最新的可用版本
+func (s *State) Unyank(launcher, version string) error {
+	o := s.currentOverride(launcher, version)
+	if !o.Yanked {
+		return nil
+	}
+	o.Yanked = false
+	o.YankReason = ""
+	if err := s.saveOverride(o); err != nil {
+		return err
+	}
+	// 恢复的版本比当前最新版本更新（或撤回后没有可用版本）时，重新选出最新版本；启动器被固定时不切换
+	s.mu.Lock()
+	_, pinned := s.pins[launcher]
+	next := s.pickNewest(launcher, s.index[launcher])
+	current := s.latest[launcher]
+	s.mu.Unlock()
+	if !pinned && next != "" && next != current {
+		return s.SwitchLatest(launcher, next, "恢复 "+version)
+	}
+	return nil
+}
+
+// SetNote 设置版本的管理员备注，note 为空时清除
+func (s *State) SetNote(launcher, version, note string) error {
+	if !s.hasVersion(launcher, version) {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errUnknownVersion)
+	}
+	o := s.currentOverride(launcher, version)
+	o.Note = note
+	return s.saveOverride(o)
+}
+
+// replaceLatest 在 version 不能再作为最新版本时，切换到其余版本中最新的一个；没有可用版本时清除标记
+func (s *State) replaceLatest(launcher, version, reason string) error {
+	s.mu.Lock()
+	if s.latest[launcher] != version {
+		s.mu.Unlock()
+		return nil
+	}
+	infoPath := s.index[launcher][version]
+	next := s.pickLatest(launcher, s.index[launcher])
+	s.mu.Unlock()
+
+	if next != "" {
+		return s.SwitchLatest(launcher, next, reason)
+	}
+	if err := s.setLatestFlag(infoPath, false); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.latest, launcher)
+	s.mu.Unlock()
+	return nil
+}
+
+// Overrides 返回所有版本干预与固定设置
+func (s *State) Overrides() (map[string]string, []Override) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pins := make(map[string]string, len(s.pins))
+	for l, v := range s.pins {
+		pins[l] = v
+	}
+	list := []Override{}
+	for _, versions := range s.overrides {
+		for _, o := range versions {
+			list = append(list, o)
+		}
+	}
+	return pins, list
+}
+
+// requireAdmin 校验 Authorization: Bearer <admin_token>。未配置令牌时管理接口不可用。
+func (s *State) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authorizeAdmin(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// authorizeAdmin 校验管理员令牌，失败时写入 403 或 401 响应并返回 false
+func (s *State) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.AdminToken == "" {
+		http.Error(w, "Admin API Disabled", http.StatusForbidden)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) != 1 {
+		log.Printf("安全警告：来自 %s 的管理接口请求认证失败：%s", r.RemoteAddr, r.URL.Path)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// adminRoutes 注册管理接口
+func (s *State) adminRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/admin/overrides", s.requireAdmin(s.handleAdminOverrides))
+	mux.HandleFunc("/api/admin/launchers", s.requireAdmin(s.handleLaunchers))
+	mux.HandleFunc("/api/admin/launchers/", s.requireAdmin(s.handleLauncher))
+	mux.HandleFunc("/api/admin/pin", s.requireAdmin(s.adminAction(func(req adminRequest) error {
+		return s.Pin(req.Launcher, req.Version)
+	})))
+	mux.HandleFunc("/api/admin/promote", s.requireAdmin(s.adminAction(func(req adminRequest) error {
+		return s.Promote(req.Launcher, req.Version, "管理员批准")
+	})))
+	mux.HandleFunc("/api/admin/unpin", s.requireAdmin(s.adminAction(func(req adminRequest) error {
+		return s.Unpin(req.Launcher)
+	})))
+	mux.HandleFunc("/api/admin/yank", s.requireAdmin(s.adminAction(func(req adminRequest) error {
+		return s.Yank(req.Launcher, req.Version, req.Reason)
+	})))
+	mux.HandleFunc("/api/admin/unyank", s.requireAdmin(s.adminAction(func(req adminRequest) error {
+		return s.Unyank(req.Launcher, req.Version)
+	})))
+	mux.HandleFunc("/api/admin/note", s.requireAdmin(s.adminAction(func(req adminRequest) error {
+		return s.SetNote(req.Launcher, req.Version, req.Note)
+	})))
+}
+
+// adminAction 将 POST 请求体解析为 adminRequest 并执行 fn
+func (s *State) adminAction(fn func(req adminRequest) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req adminRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if req.Launcher == "" || (req.Version == "" && !strings.HasSuffix(r.URL.Path, "/unpin")) {
+			http.Error(w, "launcher 与 version 不能为空", http.StatusBadRequest)
+			return
+		}
+		if err := fn(req); err != nil {
+			log.Printf("管理操作 %s 失败: %v", r.URL.Path, err)
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, errUnknownVersion):
+				status = http.StatusNotFound
+			case errors.Is(err, errYanked):
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		log.Printf("管理操作 %s: %s/%s", r.URL.Path, req.Launcher, req.Version)
+		s.handleAdminOverrides(w, r)
+	}
+}
+
+// handleAdminOverrides 返回当前所有固定版本、撤回与备注
+func (s *State) handleAdminOverrides(w http.ResponseWriter, r *http.Request) {
+	pins, list := s.Overrides()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"pins": pins, "overrides": list})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"lemwood_mirror/internal/downloader"
+	"lemwood_mirror/internal/federation"
+	"lemwood_mirror/internal/stats"
+)
+
+// countryWait 是重定向前等待查询客户端所在国家的最长时间，超时则由本节点提供
+const countryWait = 300 * time.Millisecond
+
+// Catalog 返回全部已索引版本中的资源，供联邦检查其他节点的内容
+func (s *State) Catalog() []federation.File {
+	s.mu.RLock()
+	paths := make(map[[2]string]string)
+	for launcher, versions := range s.index {
+		for v, p := range versions {
+			paths[[2]string{launcher, v}] = p
+		}
+	}
+	s.mu.RUnlock()
+
+	var files []federation.File
+	for lv, p := range paths {
+		info, err := s.readInfo(p)
+		if err != nil {
+			continue
+		}
+		files = append(files, assetFiles(lv[0], lv[1], info)...)
+	}
+	return files
+}
+
+// assetFiles 从 index.json 的内容中取出资源的大小与 SHA-256
+func assetFiles(launcher, version string, info map[string]any) []federation.File {
+	assets, _ := info["assets"].([]any)
+	files := make([]federation.File, 0, len(assets))
+	for _, a := range assets {
+		am, ok := a.(map[string]any)
+		if !ok {
+			continue
+		}
+		f := federation.File{Launcher: launcher, Version: version}
+		f.Name, _ = am["name"].(string)
+		f.SHA256, _ = am["sha256"].(string)
+		if size, ok := am["size"].(float64); ok {
+			f.Size = int64(size)
+		}
+		if f.Name != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// federatedURL 返回将下载请求重定向到的联邦节点地址，返回空字符串时由本节点提供。
+// 只重定向 index.json 中列出的资源；带 noredirect 参数的请求（来自其他节点的重定向）不再重定向。
+func (s *State) federatedURL(r *http.Request, launcher, version, name string) string {
+	if s.Federation == nil || r.URL.Query().Has("noredirect") || downloader.IsSidecar(name) {
+		return ""
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return ""
+	}
+	s.mu.RLock()
+	infoPath, ok := s.index[launcher][version]
+	s.mu.RUnlock()
+	if !ok {
+		return ""
+	}
+	info, err := s.readInfo(infoPath)
+	if err != nil {
+		return ""
+	}
+	for _, f := range assetFiles(launcher, version, info) {
+		if f.Name == name {
+			code, country := stats.ClientCountry(r, countryWait)
+			return s.Federation.Route(code, country, f)
+		}
+	}
+	return ""
+}
+
+// handleFederation 返回联邦节点的健康状况、内容检查结果与重定向统计
+func (s *State) handleFederation(w http.ResponseWriter, r *http.Request) {
+	if s.Federation == nil {
+		http.Error(w, "Federation Disabled", http.StatusServiceUnavailable)
+		return
+	}
+	st, err := s.Federation.Status()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("获取联邦状态失败: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
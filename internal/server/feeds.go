@@ -0,0 +1,375 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"lemwood_mirror/internal/markdown"
+)
+
+// 每个订阅源最多包含的条目数
+const feedMaxEntries = 50
+
+// feedEntry 是生成 Atom/RSS 条目所需的版本信息
+type feedEntry struct {
+	Launcher    string
+	Version     string
+	Title       string
+	Author      string
+	UpstreamURL string
+	PublishedAt time.Time
+	NotesHTML   string
+	Assets      []feedAsset
+}
+
+type feedAsset struct {
+	Name        string
+	URL         string
+	Size        int64
+	ContentType string
+}
+
+type cachedFeed struct {
+	gen  uint64
+	body []byte
+}
+
+// handleFeed 提供 /feeds/all.atom、/feeds/all.rss、/feeds/{launcher}.atom 与 /feeds/{launcher}.rss。
+// 订阅源在首次请求时生成并缓存，UpdateIndex 记录到新版本后缓存失效并在下次请求时重新生成。
+func (s *State) handleFeed(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	var format, contentType string
+	switch {
+	case strings.HasSuffix(name, ".atom"):
+		format, contentType = "atom", "application/atom+xml; charset=utf-8"
+	case strings.HasSuffix(name, ".rss"):
+		format, contentType = "rss", "application/rss+xml; charset=utf-8"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	launcher := strings.TrimSuffix(strings.TrimSuffix(name, ".atom"), ".rss")
+	if launcher == "" || strings.Contains(launcher, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	_, known := s.index[launcher]
+	gen := s.feedGen
+	s.mu.RUnlock()
+	if launcher != "all" && !known {
+		http.NotFound(w, r)
+		return
+	}
+
+	// 站点地址由请求推断时随 Host 头变化，不缓存，避免缓存被任意 Host 撑大
+	base := s.siteBase(r)
+	cacheable := s.SiteURL != ""
+	var cached cachedFeed
+	ok := false
+	if cacheable {
+		s.feedMu.Lock()
+		cached, ok = s.feedCache[name]
+		s.feedMu.Unlock()
+	}
+	if !ok || cached.gen != gen {
+		body, err := s.buildFeed(format, launcher, base)
+		if err != nil {
+			log.Printf("生成订阅源 %s 失败: %v", name, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		cached = cachedFeed{gen: gen, body: body}
+		if cacheable {
+			s.feedMu.Lock()
+			s.feedCache[name] = cached
+			s.feedMu.Unlock()
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(cached.body)
+}
+
+// siteBase 返回生成绝对链接所用的站点根地址。未配置 SiteURL 时根据请求推断。
+func (s *State) siteBase(r *http.Request) string {
+	if s.SiteURL != "" {
+		return s.SiteURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedEntries 收集指定启动器（"all" 表示全部）的版本，按发布时间倒序排列。
+func (s *State) feedEntries(launcher string) []feedEntry {
+	type ref struct{ launcher, version, path string }
+	var refs []ref
+	s.mu.RLock()
+	for l, versions := range s.index {
+		if launcher != "all" && l != launcher {
+			continue
+		}
+		for v, p := range versions {
+			if o, _ := s.override(l, v); o.Yanked || s.isPending(l, v) {
+				continue
+			}
+			refs = append(refs, ref{l, v, p})
+		}
+	}
+	s.mu.RUnlock()
+
+	var entries []feedEntry
+	for _, rf := range refs {
+		info, err := s.readInfo(rf.path)
+		if err != nil {
+			continue
+		}
+		e := feedEntry{Launcher: rf.launcher, Version: rf.version}
+		e.Title, _ = info["name"].(string)
+		if e.Title == "" {
+			e.Title = rf.version
+		}
+		e.Author, _ = info["author"].(string)
+		e.UpstreamURL, _ = info["html_url"].(string)
+		if p, ok := info["published_at"].(string); ok {
+			e.PublishedAt, _ = time.Parse(time.RFC3339, p)
+		}
+		body, _ := info["body"].(string)
+		e.NotesHTML = markdown.ToHTML(body)
+		if assets, ok := info["assets"].([]any); ok {
+			for _, a := range assets {
+				am, ok := a.(map[string]any)
+				if !ok {
+					continue
+				}
+				fa := feedAsset{}
+				fa.Name, _ = am["name"].(string)
+				fa.URL, _ = am["url"].(string)
+				fa.ContentType, _ = am["content_type"].(string)
+				if size, ok := am["size"].(float64); ok {
+					fa.Size = int64(size)
+				}
+				if fa.URL != "" {
+					e.Assets = append(e.Assets, fa)
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].PublishedAt.After(entries[j].PublishedAt)
+	})
+	if len(entries) > feedMaxEntries {
+		entries = entries[:feedMaxEntries]
+	}
+	return entries
+}
+
+// entryContentHTML 生成条目正文：release 说明 + 镜像下载链接列表
+func entryContentHTML(e feedEntry) string {
+	var b strings.Builder
+	b.WriteString(e.NotesHTML)
+	if len(e.Assets) > 0 {
+		b.WriteString("<h3>镜像下载</h3>\n<ul>\n")
+		for _, a := range e.Assets {
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a> (%d bytes)</li>\n", html.EscapeString(a.URL), html.EscapeString(a.Name), a.Size)
+		}
+		b.WriteString("</ul>\n")
+	}
+	return b.String()
+}
+
+func entryTitle(e feedEntry) string {
+	if e.Title == e.Version {
+		return e.Launcher + " " + e.Version
+	}
+	return fmt.Sprintf("%s %s (%s)", e.Launcher, e.Title, e.Version)
+}
+
+func (s *State) buildFeed(format, launcher, base string) ([]byte, error) {
+	entries := s.feedEntries(launcher)
+	title := "柠枺镜像 - 全部启动器"
+	if launcher != "all" {
+		title = "柠枺镜像 - " + launcher
+	}
+	selfURL := fmt.Sprintf("%s/feeds/%s.%s", base, launcher, format)
+
+	var doc any
+	if format == "atom" {
+		doc = buildAtom(entries, title, base, selfURL)
+	} else {
+		doc = buildRSS(entries, title, base, selfURL)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+func buildAtom(entries []feedEntry, title, base, selfURL string) atomFeed {
+	feed := atomFeed{
+		Title: title,
+		ID:    selfURL,
+		Links: []atomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: base + "/", Rel: "alternate", Type: "text/html"},
+		},
+	}
+	updated := time.Unix(0, 0).UTC()
+	for _, e := range entries {
+		if e.PublishedAt.After(updated) {
+			updated = e.PublishedAt
+		}
+		releaseURL := fmt.Sprintf("%s/api/release/%s/%s", base, e.Launcher, e.Version)
+		ae := atomEntry{
+			Title:     entryTitle(e),
+			ID:        releaseURL,
+			Updated:   e.PublishedAt.UTC().Format(time.RFC3339),
+			Published: e.PublishedAt.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Href: releaseURL, Rel: "alternate", Type: "application/json"}},
+			Content:   atomContent{Type: "html", Body: entryContentHTML(e)},
+		}
+		if e.UpstreamURL != "" {
+			ae.Links = append(ae.Links, atomLink{Href: e.UpstreamURL, Rel: "via", Type: "text/html"})
+		}
+		for _, a := range e.Assets {
+			ae.Links = append(ae.Links, atomLink{Href: a.URL, Rel: "enclosure", Type: a.ContentType, Title: a.Name, Length: a.Size})
+		}
+		if e.Author != "" {
+			ae.Author = &atomPerson{Name: e.Author}
+		} else {
+			ae.Author = &atomPerson{Name: e.Launcher}
+		}
+		feed.Entries = append(feed.Entries, ae)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	return feed
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	AtomLink      rssAtomLink `xml:"atom:link"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Category    string        `xml:"category"`
+	Description string        `xml:"description"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func buildRSS(entries []feedEntry, title, base, selfURL string) rssFeed {
+	ch := rssChannel{
+		Title:       title,
+		Link:        base + "/",
+		Description: "柠枺镜像新同步的启动器版本",
+		AtomLink:    rssAtomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+	}
+	if len(entries) > 0 {
+		ch.LastBuildDate = entries[0].PublishedAt.UTC().Format(time.RFC1123Z)
+	}
+	for _, e := range entries {
+		releaseURL := fmt.Sprintf("%s/api/release/%s/%s", base, e.Launcher, e.Version)
+		item := rssItem{
+			Title:       entryTitle(e),
+			Link:        releaseURL,
+			GUID:        rssGUID{IsPermaLink: false, Value: releaseURL},
+			PubDate:     e.PublishedAt.UTC().Format(time.RFC1123Z),
+			Category:    e.Launcher,
+			Description: entryContentHTML(e),
+		}
+		// RSS 2.0 每个条目只允许一个 enclosure，取第一个资源，其余见正文链接
+		if len(e.Assets) > 0 {
+			a := e.Assets[0]
+			ct := a.ContentType
+			if ct == "" {
+				ct = "application/octet-stream"
+			}
+			item.Enclosure = &rssEnclosure{URL: a.URL, Length: a.Size, Type: ct}
+		}
+		ch.Items = append(ch.Items, item)
+	}
+	return rssFeed{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", Channel: ch}
+}
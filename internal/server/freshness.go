@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"lemwood_mirror/internal/events"
+	"lemwood_mirror/internal/scans"
+)
+
+// Freshness 描述启动器镜像与上游的同步情况
+type Freshness struct {
+	Launcher string `json:"launcher"`
+	scans.Summary
+	// MirroredVersion 为当前对外提供的最新版本
+	MirroredVersion string `json:"mirrored_version"`
+	// InSync 表示最近看到的上游版本已经镜像（包括待发布的版本），或已被管理员撤回
+	InSync bool `json:"in_sync"`
+	// LagSeconds 为上游版本出现至今仍未镜像的时长，已同步时为 0
+	LagSeconds  int64  `json:"lag_seconds"`
+	Stale       bool   `json:"stale"`
+	StaleReason string `json:"stale_reason,omitempty"`
+}
+
+// Freshness 计算启动器的同步情况
+func (s *State) Freshness(launcher string, now time.Time) (Freshness, error) {
+	f := Freshness{Launcher: launcher, InSync: true}
+	sum, err := scans.Summarize(launcher)
+	if err != nil {
+		return f, err
+	}
+	f.Summary = sum
+
+	s.mu.RLock()
+	f.MirroredVersion = s.latest[launcher]
+	mirrored := s.hasVersionLocked(launcher, sum.UpstreamVersion)
+	o, _ := s.override(launcher, sum.UpstreamVersion)
+	s.mu.RUnlock()
+
+	var lag time.Duration
+	if sum.UpstreamVersion != "" && !mirrored && !o.Yanked {
+		f.InSync = false
+		if sum.UpstreamSeenAt != nil {
+			lag = now.Sub(*sum.UpstreamSeenAt)
+			f.LagSeconds = int64(lag / time.Second)
+		}
+	}
+
+	threshold := s.StaleAfter
+	if threshold <= 0 {
+		return f, nil
+	}
+	switch {
+	case !f.InSync && lag > threshold:
+		f.Stale = true
+		f.StaleReason = fmt.Sprintf("上游版本 %s 已出现 %s，仍未同步", sum.UpstreamVersion, lag.Round(time.Minute))
+	case sum.ConsecutiveFailures > 0 && sum.LastScan != nil:
+		since := sum.LastScan.StartedAt
+		if sum.LastSuccess != nil {
+			since = *sum.LastSuccess
+		} else if first, ok := s.firstFailure(launcher); ok {
+			since = first
+		}
+		if now.Sub(since) > threshold {
+			f.Stale = true
+			f.StaleReason = fmt.Sprintf("连续 %d 次扫描失败，%s 内没有成功的扫描", sum.ConsecutiveFailures, now.Sub(since).Round(time.Minute))
+		}
+	}
+	return f, nil
+}
+
+// firstFailure 返回启动器最早一次扫描的开始时间，用于从未成功过的启动器
+func (s *State) firstFailure(launcher string) (time.Time, bool) {
+	list, err := scans.History(launcher, 500)
+	if err != nil || len(list) == 0 {
+		return time.Time{}, false
+	}
+	return list[len(list)-1].StartedAt, true
+}
+
+// hasVersionLocked 判断版本是否在索引中，调用方需持有 mu
+func (s *State) hasVersionLocked(launcher, version string) bool {
+	_, ok := s.index[launcher][version]
+	return ok
+}
+
+// CheckStale 在扫描结束后检查启动器是否过期：开始过期时发出告警，恢复同步时记录事件，同一次过期只告警一次
+func (s *State) CheckStale(launcher string) {
+	f, err := s.Freshness(launcher, time.Now())
+	if err != nil {
+		log.Printf("%s: 计算同步状态失败: %v", launcher, err)
+		return
+	}
+	s.mu.Lock()
+	was := s.stale[launcher]
+	if f.Stale {
+		s.stale[launcher] = true
+	} else {
+		delete(s.stale, launcher)
+	}
+	s.mu.Unlock()
+	switch {
+	case f.Stale && !was:
+		events.Alert(events.KindStale, launcher, "%s", f.StaleReason)
+	case !f.Stale && was:
+		events.Info(events.KindRecovered, launcher, "已恢复同步，当前版本 %s", f.MirroredVersion)
+	}
+}
+
+// launcherNames 返回已配置或已镜像的启动器名称
+func (s *State) launcherNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	if s.Launchers != nil {
+		for _, l := range s.Launchers.List() {
+			if !seen[l.Name] {
+				seen[l.Name] = true
+				names = append(names, l.Name)
+			}
+		}
+	}
+	s.mu.RLock()
+	for l := range s.index {
+		if !seen[l] {
+			seen[l] = true
+			names = append(names, l)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// handleScanHistory 返回扫描记录，支持 launcher 与 limit 查询参数
+func (s *State) handleScanHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	list, err := scans.History(q.Get("launcher"), limit)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("获取扫描记录失败: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleScanStatus 返回各启动器的同步情况，支持 launcher 查询参数
+func (s *State) handleScanStatus(w http.ResponseWriter, r *http.Request) {
+	names := s.launcherNames()
+	if l := r.URL.Query().Get("launcher"); l != "" {
+		names = []string{l}
+	}
+	now := time.Now()
+	list := []Freshness{}
+	for _, name := range names {
+		f, err := s.Freshness(name, now)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Printf("计算 %s 的同步状态失败: %v", name, err)
+			return
+		}
+		list = append(list, f)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 )
@@ -12,16 +11,7 @@ func StartHTTPWithScan(addr string, s *State, scanFunc func()) error {
 	s.Routes(mux)
 
 	// 手动扫描端点
-	mux.HandleFunc("/api/scan", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		// 异步触发扫描
-		go scanFunc()
-		w.WriteHeader(http.StatusAccepted)
-		fmt.Fprintln(w, "Scan triggered")
-	})
+	mux.HandleFunc("/api/scan", s.handleScan(scanFunc))
 
 	// 应用安全中间件
 	handler := SecurityMiddleware(mux)
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"lemwood_mirror/internal/db"
+)
+
+// LatestSwitch 是一次最新版本切换的记录
+type LatestSwitch struct {
+	ID          int64     `json:"id"`
+	Launcher    string    `json:"launcher"`
+	FromVersion string    `json:"from_version"`
+	ToVersion   string    `json:"to_version"`
+	Reason      string    `json:"reason"`
+	SwitchedAt  time.Time `json:"switched_at"`
+}
+
+// SwitchLatest 将启动器的最新版本事务性地切换到 version。
+// 先标记新版本，再清除旧版本的标记；任何一步失败都会恢复切换前的状态。
+// 切换成功后记录到 latest_switches 表。version 必须已通过 UpdateIndex 加入索引。
+func (s *State) SwitchLatest(launcher, version, reason string) error {
+	s.switchMu.Lock()
+	defer s.switchMu.Unlock()
+
+	s.mu.RLock()
+	versions := make(map[string]string, len(s.index[launcher]))
+	for v, p := range s.index[launcher] {
+		versions[v] = p
+	}
+	previous := s.latest[launcher]
+	s.mu.RUnlock()
+
+	infoPath, ok := versions[version]
+	if !ok {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errUnknownVersion)
+	}
+	if yanked, _ := s.Yanked(launcher, version); yanked {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errYanked)
+	}
+
+	// 记录当前带有 latest 标记的版本，用于回滚
+	var flagged []string
+	for v, p := range versions {
+		if v == version {
+			continue
+		}
+		if latest, err := readLatestFlag(p); err == nil && latest {
+			flagged = append(flagged, v)
+		}
+	}
+	newWasLatest, err := readLatestFlag(infoPath)
+	if err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", infoPath, err)
+	}
+
+	if !newWasLatest {
+		if err := s.setLatestFlag(infoPath, true); err != nil {
+			return fmt.Errorf("标记 %s 为最新版本失败: %w", version, err)
+		}
+	}
+	var cleared []string
+	for _, v := range flagged {
+		if err := s.setLatestFlag(versions[v], false); err != nil {
+			// 回滚：恢复已清除的旧标记，撤销新版本的标记
+			for _, c := range cleared {
+				if rerr := s.setLatestFlag(versions[c], true); rerr != nil {
+					log.Printf("回滚 %s/%s 的 latest 标记失败: %v", launcher, c, rerr)
+				}
+			}
+			if !newWasLatest {
+				if rerr := s.setLatestFlag(infoPath, false); rerr != nil {
+					log.Printf("回滚 %s/%s 的 latest 标记失败: %v", launcher, version, rerr)
+				}
+			}
+			return fmt.Errorf("清除 %s 的 latest 标记失败，已回滚: %w", v, err)
+		}
+		cleared = append(cleared, v)
+	}
+
+	s.mu.Lock()
+	s.latest[launcher] = version
+	s.mu.Unlock()
+
+	if previous == version && len(flagged) == 0 && newWasLatest {
+		return nil
+	}
+	if db.DB != nil {
+		if _, err := db.DB.Exec(`INSERT INTO latest_switches (launcher, from_version, to_version, reason, switched_at) VALUES (?, ?, ?, ?, ?)`,
+			launcher, previous, version, reason, time.Now()); err != nil {
+			log.Printf("记录 %s 的最新版本切换失败: %v", launcher, err)
+		}
+	}
+	log.Printf("%s: 最新版本已从 %q 切换到 %q（%s）", launcher, previous, version, reason)
+	return nil
+}
+
+// readLatestFlag 直接从文件读取 is_latest 标记，不使用缓存
+func readLatestFlag(infoPath string) (bool, error) {
+	content, err := os.ReadFile(infoPath)
+	if err != nil {
+		return false, err
+	}
+	var info map[string]any
+	if err := json.Unmarshal(content, &info); err != nil {
+		return false, err
+	}
+	latest, _ := info["is_latest"].(bool)
+	return latest, nil
+}
+
+// setLatestFlag 设置单个 index.json 的 is_latest 标记。先写临时文件再重命名，并更新缓存。
+func (s *State) setLatestFlag(infoPath string, latest bool) error {
+	content, err := os.ReadFile(infoPath)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	var info map[string]any
+	if err := json.Unmarshal(content, &info); err != nil {
+		return fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+	info["is_latest"] = latest
+	newContent, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 JSON 失败: %w", err)
+	}
+	tmp := infoPath + ".tmp"
+	if err := os.WriteFile(tmp, newContent, 0o644); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, infoPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	s.mu.Lock()
+	s.infoCache[infoPath] = info
+	s.mu.Unlock()
+	return nil
+}
+
+// LatestHistory 返回最新版本切换记录，launcher 为空时返回全部启动器
+func LatestHistory(launcher string, limit int) ([]LatestSwitch, error) {
+	if db.DB == nil {
+		return nil, errors.New("数据库未初始化")
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT id, launcher, from_version, to_version, reason, switched_at FROM latest_switches`
+	var args []any
+	if launcher != "" {
+		query += ` WHERE launcher = ?`
+		args = append(args, launcher)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []LatestSwitch{}
+	for rows.Next() {
+		var sw LatestSwitch
+		if err := rows.Scan(&sw.ID, &sw.Launcher, &sw.FromVersion, &sw.ToVersion, &sw.Reason, &sw.SwitchedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, sw)
+	}
+	return list, rows.Err()
+}
+
+// handleLatestHistory 返回最新版本切换记录，支持 launcher 与 limit 查询参数
+func (s *State) handleLatestHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	list, err := LatestHistory(q.Get("launcher"), limit)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("获取最新版本切换记录失败: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"lemwood_mirror/internal/browser"
+	"lemwood_mirror/internal/config"
+	"lemwood_mirror/internal/version"
+)
+
+// dryRunTimeout 为试解析启动器来源的时间上限，需小于 HTTP 服务器的写超时
+const dryRunTimeout = 12 * time.Second
+
+// launcherResponse 是启动器管理接口的响应
+type launcherResponse struct {
+	Launcher config.LauncherConfig `json:"launcher"`
+	// Resolved 为试解析的结果：GitHub 来源为仓库地址，下载页来源为找到的版本号
+	Resolved string `json:"resolved,omitempty"`
+}
+
+// dryRunLauncher 校验启动器定义，并试解析其来源，不下载任何文件
+func (s *State) dryRunLauncher(ctx context.Context, l config.LauncherConfig) (version.Comparator, string, error) {
+	if err := l.Validate(); err != nil {
+		return nil, "", err
+	}
+	cmp, err := version.New(version.Spec{Scheme: l.VersionScheme, Pattern: l.VersionRegex, Order: l.VersionRegexOrder})
+	if err != nil {
+		return nil, "", fmt.Errorf("版本排序方案配置无效: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, dryRunTimeout)
+	defer cancel()
+	// 与实际扫描使用同一解析器的代理、User-Agent、超时与重试策略
+	resolver := s.Resolver
+	if resolver == nil {
+		resolver = browser.NewResolver(browser.ResolverOptions{})
+	}
+	if l.IsWebpage() {
+		rel, err := resolver.WebRelease(ctx, l.SourceURL, browser.WebSpec{
+			LinkSelector:   l.LinkSelector,
+			AssetPattern:   l.AssetPattern,
+			VersionPattern: l.VersionPattern,
+			Compare:        cmp,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("抓取下载页失败: %w", err)
+		}
+		return cmp, rel.Version(), nil
+	}
+	repoURL, err := resolver.Lookup(ctx, l.SourceURL, l.Selectors()...)
+	if err != nil {
+		return nil, "", fmt.Errorf("解析仓库地址失败: %w", err)
+	}
+	return cmp, repoURL, nil
+}
+
+// applyLauncher 在启动器定义保存后生效：更新版本排序方案、清除旧的仓库信任记录，并立即调度一次扫描
+func (s *State) applyLauncher(l config.LauncherConfig, cmp version.Comparator, sourceChanged bool) {
+	s.SetVersionScheme(l.Name, cmp)
+	if sourceChanged && s.Resolver != nil {
+		if err := s.Resolver.Forget(l.Name); err != nil {
+			log.Printf("%s: %v", l.Name, err)
+		}
+	}
+	if !l.Disabled && s.ScanLauncher != nil {
+		go s.ScanLauncher(l.Name, ScanOptions{})
+	}
+}
+
+// handleLaunchers 处理 /api/admin/launchers：GET 列出全部启动器，POST 添加启动器
+func (s *State) handleLaunchers(w http.ResponseWriter, r *http.Request) {
+	if s.Launchers == nil {
+		http.Error(w, "Launcher Management Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Launchers.List())
+	case http.MethodPost:
+		l, ok := decodeLauncher(w, r)
+		if !ok {
+			return
+		}
+		if _, exists := s.Launchers.Get(l.Name); exists {
+			http.Error(w, fmt.Sprintf("%v: %s", config.ErrLauncherExists, l.Name), http.StatusConflict)
+			return
+		}
+		s.saveLauncher(w, r, l, s.Launchers.Create, true)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLauncher 处理 /api/admin/launchers/{name}：GET 查看、PUT 修改、DELETE 删除，
+// 以及 POST /api/admin/launchers/{name}/disable 与 /enable
+func (s *State) handleLauncher(w http.ResponseWriter, r *http.Request) {
+	if s.Launchers == nil {
+		http.Error(w, "Launcher Management Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/launchers/"), "/")
+	current, ok := s.Launchers.Get(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(launcherResponse{Launcher: current})
+	case action == "" && r.Method == http.MethodPut:
+		l, ok := decodeLauncher(w, r)
+		if !ok {
+			return
+		}
+		if l.Name != name {
+			http.Error(w, "不能修改启动器名称", http.StatusBadRequest)
+			return
+		}
+		changed := l.SourceURL != current.SourceURL || l.SourceType != current.SourceType
+		s.saveLauncher(w, r, l, s.Launchers.Update, changed)
+	case action == "" && r.Method == http.MethodDelete:
+		if err := s.Launchers.Delete(name); err != nil {
+			writeLauncherError(w, name, err)
+			return
+		}
+		if s.Resolver != nil {
+			if err := s.Resolver.Forget(name); err != nil {
+				log.Printf("%s: %v", name, err)
+			}
+		}
+		log.Printf("管理操作: 已删除启动器 %s，已镜像的文件保留", name)
+		w.WriteHeader(http.StatusNoContent)
+	case (action == "disable" || action == "enable") && r.Method == http.MethodPost:
+		current.Disabled = action == "disable"
+		if err := s.Launchers.Update(current); err != nil {
+			writeLauncherError(w, name, err)
+			return
+		}
+		log.Printf("管理操作: 启动器 %s 已%s", name, map[bool]string{true: "禁用", false: "启用"}[current.Disabled])
+		if !current.Disabled && s.ScanLauncher != nil {
+			go s.ScanLauncher(name, ScanOptions{})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(launcherResponse{Launcher: current})
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// saveLauncher 试解析启动器来源，成功后用 save 写回配置并使其生效
+func (s *State) saveLauncher(w http.ResponseWriter, r *http.Request, l config.LauncherConfig, save func(config.LauncherConfig) error, sourceChanged bool) {
+	if err := l.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cmp, resolved, err := s.dryRunLauncher(r.Context(), l)
+	if err != nil {
+		log.Printf("启动器 %s 试解析失败: %v", l.Name, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := save(l); err != nil {
+		writeLauncherError(w, l.Name, err)
+		return
+	}
+	s.applyLauncher(l, cmp, sourceChanged)
+	log.Printf("管理操作: 已保存启动器 %s（%s）", l.Name, resolved)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(launcherResponse{Launcher: l, Resolved: resolved})
+}
+
+// decodeLauncher 解析请求体中的启动器定义，失败时写入 400 响应
+func decodeLauncher(w http.ResponseWriter, r *http.Request) (config.LauncherConfig, bool) {
+	var l config.LauncherConfig
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&l); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+		return l, false
+	}
+	return l, true
+}
+
+func writeLauncherError(w http.ResponseWriter, name string, err error) {
+	log.Printf("管理操作: 保存启动器 %s 失败: %v", name, err)
+	switch {
+	case errors.Is(err, config.ErrLauncherNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, config.ErrLauncherExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
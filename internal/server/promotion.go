@@ -0,0 +1,238 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"lemwood_mirror/internal/db"
+	"lemwood_mirror/internal/version"
+)
+
+// Promotion 描述新版本成为最新版本的方式。Manual 为 true 时需要管理员批准；
+// 否则 Soak 大于 0 时新版本在观察期结束后自动成为最新版本，为 0 时立即成为最新版本。
+type Promotion struct {
+	Manual bool
+	Soak   time.Duration
+}
+
+// Pending 是已镜像但尚未成为最新版本的版本
+type Pending struct {
+	Launcher     string     `json:"launcher"`
+	Version      string     `json:"version"`
+	MirroredAt   time.Time  `json:"mirrored_at"`
+	PromoteAfter *time.Time `json:"promote_after,omitempty"` // 为空表示等待管理员批准
+}
+
+// loadPending 从数据库加载待发布版本，调用方需持有 mu
+func (s *State) loadPending() error {
+	rows, err := db.DB.Query(`SELECT launcher, version, mirrored_at, promote_after FROM pending_versions`)
+	if err != nil {
+		return fmt.Errorf("读取待发布版本失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p Pending
+		var after sql.NullTime
+		if err := rows.Scan(&p.Launcher, &p.Version, &p.MirroredAt, &after); err != nil {
+			return fmt.Errorf("读取待发布版本失败: %w", err)
+		}
+		if after.Valid {
+			t := after.Time
+			p.PromoteAfter = &t
+		}
+		if s.pending[p.Launcher] == nil {
+			s.pending[p.Launcher] = make(map[string]Pending)
+		}
+		s.pending[p.Launcher][p.Version] = p
+	}
+	return rows.Err()
+}
+
+// isPending 判断版本是否待发布，调用方需持有 mu
+func (s *State) isPending(launcher, version string) bool {
+	_, ok := s.pending[launcher][version]
+	return ok
+}
+
+// Stage 在刚镜像完成的版本加入索引之前按发布方式处理：需要观察期或人工批准时记为待发布并返回 true，
+// 这样加入索引时它不会被选为最新版本；立即发布时返回 false，由调用方在加入索引后切换最新版本。
+// 已是最新版本或已在待发布列表中的版本不重复登记，重复扫描不会重置观察期。
+func (s *State) Stage(launcher, version string, p Promotion) (bool, error) {
+	s.mu.RLock()
+	current := s.latest[launcher]
+	pending := s.isPending(launcher, version)
+	s.mu.RUnlock()
+	if pending {
+		return true, nil
+	}
+	if current == version || (!p.Manual && p.Soak <= 0) {
+		return false, nil
+	}
+
+	entry := Pending{Launcher: launcher, Version: version, MirroredAt: time.Now()}
+	var after sql.NullTime
+	if !p.Manual {
+		t := entry.MirroredAt.Add(p.Soak)
+		entry.PromoteAfter = &t
+		after = sql.NullTime{Time: t, Valid: true}
+	}
+	if db.DB != nil {
+		if _, err := db.DB.Exec(`INSERT INTO pending_versions (launcher, version, mirrored_at, promote_after) VALUES (?, ?, ?, ?)
+            ON CONFLICT(launcher, version) DO NOTHING`, launcher, version, entry.MirroredAt, after); err != nil {
+			return false, fmt.Errorf("保存待发布版本失败: %w", err)
+		}
+	}
+	s.mu.Lock()
+	if s.pending[launcher] == nil {
+		s.pending[launcher] = make(map[string]Pending)
+	}
+	s.pending[launcher][version] = entry
+	s.feedGen++
+	s.mu.Unlock()
+	if entry.PromoteAfter != nil {
+		log.Printf("%s: 新版本 %s 进入观察期，将于 %s 成为最新版本", launcher, version, entry.PromoteAfter.Format("2006-01-02 15:04"))
+	} else {
+		log.Printf("%s: 新版本 %s 等待管理员批准", launcher, version)
+	}
+	return true, nil
+}
+
+// clearPending 将版本移出待发布列表
+func (s *State) clearPending(launcher, version string) error {
+	if db.DB != nil {
+		if _, err := db.DB.Exec(`DELETE FROM pending_versions WHERE launcher = ? AND version = ?`, launcher, version); err != nil {
+			return fmt.Errorf("删除待发布版本失败: %w", err)
+		}
+	}
+	s.mu.Lock()
+	if _, ok := s.pending[launcher][version]; ok {
+		delete(s.pending[launcher], version)
+		s.feedGen++
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Promote 批准待发布版本并将其切换为最新版本。启动器被固定时只移出待发布列表，不切换。
+func (s *State) Promote(launcher, version, reason string) error {
+	if !s.hasVersion(launcher, version) {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errUnknownVersion)
+	}
+	if yanked, _ := s.Yanked(launcher, version); yanked {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errYanked)
+	}
+	if err := s.clearPending(launcher, version); err != nil {
+		return err
+	}
+	if pinned := s.Pinned(launcher); pinned != "" {
+		log.Printf("%s: 最新版本已被管理员固定为 %s，%s 仅移出待发布列表", launcher, pinned, version)
+		return nil
+	}
+	return s.SwitchLatest(launcher, version, reason)
+}
+
+// PromoteDue 发布观察期已结束的版本。观察期内上游又出现更新的版本并已成为最新版本时，
+// 较旧的版本只移出待发布列表，不会让最新版本倒退。
+func (s *State) PromoteDue(now time.Time) {
+	var due []Pending
+	s.mu.RLock()
+	for _, versions := range s.pending {
+		for _, p := range versions {
+			if p.PromoteAfter != nil && !now.Before(*p.PromoteAfter) {
+				due = append(due, p)
+			}
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].MirroredAt.Before(due[j].MirroredAt) })
+
+	for _, p := range due {
+		s.mu.RLock()
+		path, exists := s.index[p.Launcher][p.Version]
+		current := s.latest[p.Launcher]
+		currentPath := s.index[p.Launcher][current]
+		s.mu.RUnlock()
+		cmp := s.Comparator(p.Launcher)
+		if !exists || (current != "" && cmp(s.versionInfo(p.Version, path), s.versionInfo(current, currentPath)) < 0) {
+			if err := s.clearPending(p.Launcher, p.Version); err != nil {
+				log.Printf("%s: %v", p.Launcher, err)
+			}
+			continue
+		}
+		if err := s.Promote(p.Launcher, p.Version, "观察期结束"); err != nil {
+			log.Printf("%s: 发布 %s 失败: %v", p.Launcher, p.Version, err)
+		}
+	}
+}
+
+// versionInfo 返回用于排序的版本信息，按日期排序的方案需要 index.json 中的发布时间
+func (s *State) versionInfo(tag, infoPath string) version.Info {
+	vi := version.Info{Tag: tag}
+	if infoPath == "" {
+		return vi
+	}
+	if info, err := s.readInfo(infoPath); err == nil {
+		vi.Published = publishedAt(info)
+	}
+	return vi
+}
+
+// StartPromoter 在后台按 interval 定期发布观察期已结束的版本
+func (s *State) StartPromoter(interval time.Duration) {
+	go func() {
+		for {
+			s.PromoteDue(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// handlePending 返回待发布的版本，支持 launcher 查询参数
+func (s *State) handlePending(w http.ResponseWriter, r *http.Request) {
+	launcher := r.URL.Query().Get("launcher")
+	type ref struct {
+		p    Pending
+		path string
+	}
+	var refs []ref
+	s.mu.RLock()
+	for l, versions := range s.pending {
+		if launcher != "" && l != launcher {
+			continue
+		}
+		for v, p := range versions {
+			if path, ok := s.index[l][v]; ok {
+				refs = append(refs, ref{p, path})
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	result := make(map[string][]map[string]any)
+	for _, rf := range refs {
+		info := map[string]any{"tag_name": rf.p.Version}
+		if fileInfo, err := s.readInfo(rf.path); err == nil {
+			for k, val := range fileInfo {
+				if k != "is_latest" {
+					info[k] = val
+				}
+			}
+		}
+		info["mirrored_at"] = rf.p.MirroredAt
+		if rf.p.PromoteAfter != nil {
+			info["promote_after"] = *rf.p.PromoteAfter
+		}
+		info["pending"] = true
+		result[rf.p.Launcher] = append(result[rf.p.Launcher], info)
+	}
+	for l, list := range result {
+		s.sortVersionList(l, list)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Published 返回每个启动器已发布的全部版本，包括已撤回与待发布的版本
+func (s *State) Published() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.index))
+	for l, versions := range s.index {
+		for v := range versions {
+			out[l] = append(out[l], v)
+		}
+	}
+	return out
+}
+
+// Latest 返回启动器当前的最新版本，没有时返回空字符串
+func (s *State) Latest(launcher string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest[launcher]
+}
+
+// handleReplica 返回副本与主节点的同步状况
+func (s *State) handleReplica(w http.ResponseWriter, r *http.Request) {
+	if s.Replica == nil {
+		http.Error(w, "Replica Mode Disabled", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Replica.Status())
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"lemwood_mirror/internal/downloader"
+	"lemwood_mirror/internal/version"
+)
+
+// Prune 按启动器的版本排序方案保留最新的 keep 个版本，删除其余版本目录。
+// 当前最新版本与管理员固定的版本总会保留；keep <= 0 时不删除任何版本。返回被删除的版本。
+func (s *State) Prune(launcher string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	s.mu.RLock()
+	versions := make(map[string]string, len(s.index[launcher]))
+	for v, p := range s.index[launcher] {
+		versions[v] = p
+	}
+	latest := s.latest[launcher]
+	pinned := s.pins[launcher]
+	s.mu.RUnlock()
+	if len(versions) <= keep {
+		return nil, nil
+	}
+
+	list := make([]version.Info, 0, len(versions))
+	for v, p := range versions {
+		vi := version.Info{Tag: v}
+		if info, err := s.readInfo(p); err == nil {
+			vi.Published = publishedAt(info)
+		}
+		list = append(list, vi)
+	}
+	cmp := s.Comparator(launcher)
+	sort.SliceStable(list, func(i, j int) bool { return cmp(list[i], list[j]) > 0 })
+
+	var removed []string
+	kept := 0
+	for _, vi := range list {
+		if vi.Tag == latest || vi.Tag == pinned || kept < keep {
+			kept++
+			continue
+		}
+		if err := s.deleteVersion(launcher, vi.Tag, versions[vi.Tag]); err != nil {
+			return removed, err
+		}
+		log.Printf("%s: 已按保留策略删除旧版本 %s", launcher, vi.Tag)
+		removed = append(removed, vi.Tag)
+	}
+	return removed, nil
+}
+
+// DeleteVersion 删除已发布的版本：从索引中移除，并删除版本目录、独占的资源数据与备份
+func (s *State) DeleteVersion(launcher, version string) error {
+	s.mu.RLock()
+	infoPath, ok := s.index[launcher][version]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("版本 %s/%s %w", launcher, version, errUnknownVersion)
+	}
+	return s.deleteVersion(launcher, version, infoPath)
+}
+
+func (s *State) deleteVersion(launcher, version, infoPath string) error {
+	// 先从索引中移除，停止对外提供，再删除目录
+	s.RemoveVersion(launcher, version)
+	dir := filepath.Dir(infoPath)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("删除版本 %s/%s 失败: %w", launcher, version, err)
+	}
+	// 不再被任何版本引用的资源数据一并删除
+	if s.Blobs != nil {
+		if freed, err := s.Blobs.Release(launcher, version); err != nil {
+			log.Printf("%s: 释放版本 %s 的资源数据失败: %v", launcher, version, err)
+		} else if freed > 0 {
+			log.Printf("%s: 版本 %s 独占的资源数据已删除，释放 %d 字节", launcher, version, freed)
+		}
+	}
+	// 该版本被上游替换过的旧资源备份一并删除
+	backup := filepath.Join(filepath.Dir(dir), downloader.BackupName, version)
+	if err := os.RemoveAll(backup); err != nil {
+		log.Printf("%s: 删除版本 %s 的资源备份失败: %v", launcher, version, err)
+	}
+	s.mu.Lock()
+	delete(s.infoCache, infoPath)
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"lemwood_mirror/internal/browser"
+	"lemwood_mirror/internal/downloader"
+)
+
+// ScanOptions 是手动扫描的选项
+type ScanOptions struct {
+	// Force 为 true 时即使已是最新版本也重新下载并重新校验全部资源
+	Force bool `json:"force,omitempty"`
+	// Tag 指定要镜像的上游版本，为空时镜像最新版本
+	Tag string `json:"tag,omitempty"`
+}
+
+// 试运行扫描的动作
+const (
+	PlanMirror   = "mirror"     // 将下载并发布该版本
+	PlanUpToDate = "up_to_date" // 已是最新，不做任何操作
+	PlanSkip     = "skip"       // 不会镜像，例如版本已被撤回
+	PlanError    = "error"      // 获取上游信息失败
+	PlanNoSpace  = "no_space"   // 磁盘空间或配额不足，将拒绝下载
+	// PlanRepoChanged 表示源页面指向的仓库与已信任的不一致，实际扫描会告警并按变更策略处理
+	PlanRepoChanged = "repo_changed"
+)
+
+// ScanPlan 是单个启动器的试运行结果
+type ScanPlan struct {
+	Launcher string `json:"launcher"`
+	Action   string `json:"action"`
+	Reason   string `json:"reason,omitempty"`
+	// RepoChange 为检测到的仓库变更，Plan 按变更策略下实际扫描将使用的仓库计算
+	RepoChange *browser.RepoChange `json:"repo_change,omitempty"`
+	*downloader.Plan
+}
+
+// scanRequest 是 POST /api/scan 的参数，可以放在查询参数中，也可以放在 JSON 请求体中
+type scanRequest struct {
+	Launcher  string   `json:"launcher"`
+	Launchers []string `json:"launchers"`
+	Force     bool     `json:"force"`
+	Tag       string   `json:"tag"`
+	DryRun    bool     `json:"dry_run"`
+}
+
+// names 返回去重后的启动器列表，launcher 参数可以重复，也可以用逗号分隔
+func (req scanRequest) names() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, item := range append([]string{req.Launcher}, req.Launchers...) {
+		for _, name := range strings.Split(item, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// parseScanRequest 解析查询参数与 JSON 请求体，两者同时提供时合并
+func parseScanRequest(w http.ResponseWriter, r *http.Request) (scanRequest, error) {
+	var req scanRequest
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" && r.ContentLength != 0 {
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return req, err
+		}
+	}
+	q := r.URL.Query()
+	req.Launchers = append(req.Launchers, q["launcher"]...)
+	req.Launchers = append(req.Launchers, q["launchers"]...)
+	for key, dst := range map[string]*bool{"force": &req.Force, "dry_run": &req.DryRun} {
+		if v := q.Get(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return req, fmt.Errorf("%s 参数无效: %q", key, v)
+			}
+			*dst = *dst || b
+		}
+	}
+	if tag := q.Get("tag"); tag != "" {
+		req.Tag = tag
+	}
+	req.Tag = strings.TrimSpace(req.Tag)
+	return req, nil
+}
+
+// handleScan 处理 POST /api/scan。不带参数时扫描全部启用的启动器；
+// 可以指定启动器、强制重新下载（force）、镜像指定版本（tag）或只返回计划执行的操作（dry_run）。
+// force 与 tag 会消耗大量带宽与存储，需要管理员令牌。
+func (s *State) handleScan(scanAll func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		req, err := parseScanRequest(w, r)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+			return
+		}
+		names := req.names()
+		opts := ScanOptions{Force: req.Force, Tag: req.Tag}
+		if len(names) == 0 && opts == (ScanOptions{}) && !req.DryRun {
+			// 异步触发扫描
+			go scanAll()
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, "Scan triggered")
+			return
+		}
+		if s.Launchers == nil || s.ScanLauncher == nil || s.PlanScan == nil {
+			http.Error(w, "Targeted Scan Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		if len(names) == 0 {
+			for _, l := range s.Launchers.List() {
+				if !l.Disabled {
+					names = append(names, l.Name)
+				}
+			}
+		}
+		for _, name := range names {
+			l, ok := s.Launchers.Get(name)
+			if !ok {
+				http.Error(w, fmt.Sprintf("启动器不存在: %s", name), http.StatusNotFound)
+				return
+			}
+			if l.Disabled {
+				http.Error(w, fmt.Sprintf("启动器 %s 已禁用", name), http.StatusConflict)
+				return
+			}
+			if opts.Tag != "" && l.IsWebpage() {
+				http.Error(w, fmt.Sprintf("启动器 %s 的来源为下载页，不支持指定版本", name), http.StatusBadRequest)
+				return
+			}
+		}
+		if opts.Tag != "" && len(names) != 1 {
+			http.Error(w, "指定 tag 时只能扫描一个启动器", http.StatusBadRequest)
+			return
+		}
+
+		if req.DryRun {
+			// 试运行会对每个启动器请求上游，同样只对管理员开放
+			if !s.authorizeAdmin(w, r) {
+				return
+			}
+			// 试运行需要访问上游，限制在 HTTP 写超时之内
+			ctx, cancel := context.WithTimeout(r.Context(), dryRunTimeout)
+			defer cancel()
+			plans := make([]ScanPlan, len(names))
+			var wg sync.WaitGroup
+			for i, name := range names {
+				wg.Add(1)
+				go func(i int, name string) {
+					defer wg.Done()
+					plans[i] = s.PlanScan(ctx, name, opts)
+				}(i, name)
+			}
+			wg.Wait()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(plans)
+			return
+		}
+
+		if (opts.Force || opts.Tag != "") && !s.authorizeAdmin(w, r) {
+			return
+		}
+		for _, name := range names {
+			log.Printf("手动扫描 %s（force=%t, tag=%q）", name, opts.Force, opts.Tag)
+			go s.ScanLauncher(name, opts)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{"status": "accepted", "launchers": names, "force": opts.Force, "tag": opts.Tag})
+	}
+}
@@ -1,27 +1,81 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
-
+	"time"
+
+	"lemwood_mirror/internal/blobs"
+	"lemwood_mirror/internal/browser"
+	"lemwood_mirror/internal/config"
+	"lemwood_mirror/internal/downloader"
+	"lemwood_mirror/internal/events"
+	"lemwood_mirror/internal/federation"
+	"lemwood_mirror/internal/markdown"
+	"lemwood_mirror/internal/metalink"
+	"lemwood_mirror/internal/quota"
+	"lemwood_mirror/internal/replica"
 	"lemwood_mirror/internal/stats"
+	"lemwood_mirror/internal/version"
 )
 
 type State struct {
 	BasePath string
+	// SiteURL 为镜像站对外根地址，用于订阅源中的绝对链接；为空时根据请求推断
+	SiteURL string
+	// Mirrors 为上游下载策略集合，用于展示健康状况
+	Mirrors *downloader.MirrorSet
+	// Downloader 为全局下载队列，用于展示任务状态
+	Downloader *downloader.Downloader
+	// Blobs 为按内容寻址的资源存储，删除版本时释放其引用；nil 表示不去重
+	Blobs *blobs.Store
+	// Quota 检查下载前的可用空间与配额，用于展示磁盘占用；nil 表示不检查
+	Quota *quota.Guard
+	// Federation 将下载请求按客户端所在国家重定向到其他镜像节点，nil 表示总是由本节点提供
+	Federation *federation.Federation
+	// Replica 从主节点同步版本，nil 表示本节点不是副本
+	Replica *replica.Syncer
+	// AdminToken 为管理接口的访问令牌，为空时管理接口不可用
+	AdminToken string
+	// WebhookSecret 为 GitHub webhook 的签名密钥，为空时不接收 webhook
+	WebhookSecret string
+	// Launchers 为运行期的启动器定义，管理接口的修改会写回配置文件
+	Launchers *config.Registry
+	// Resolver 用于试解析管理员提交的启动器来源
+	Resolver *browser.Resolver
+	// ScanLauncher 立即按选项扫描单个启动器，由 main 提供
+	ScanLauncher func(name string, opts ScanOptions)
+	// PlanScan 获取上游元数据并返回扫描将执行的操作，不修改磁盘，由 main 提供
+	PlanScan func(ctx context.Context, name string, opts ScanOptions) ScanPlan
+	// StaleAfter 为启动器未能同步上游多久后视为过期并告警，为 0 时不告警
+	StaleAfter time.Duration
 	// 缓存状态：map[launcher]map[version]infoPath
 	mu        sync.RWMutex
 	index     map[string]map[string]string
 	latest    map[string]string
 	infoCache map[string]map[string]interface{} // 缓存 index.json 文件内容
+	feedGen   uint64                            // 版本集合变化计数，用于使订阅源缓存失效
+	overrides map[string]map[string]Override    // 管理员对版本的撤回与备注
+	pins      map[string]string                 // 管理员固定的最新版本
+	pending   map[string]map[string]Pending     // 已镜像但尚未成为最新版本的版本
+	stale     map[string]bool                   // 已发出过期告警的启动器
+
+	switchMu sync.Mutex // 串行化最新版本切换
+
+	schemeMu sync.RWMutex
+	schemes  map[string]version.Comparator // 各启动器的版本排序方案
+
+	feedMu    sync.Mutex
+	feedCache map[string]cachedFeed
 }
 
 func NewState(base string) *State {
@@ -30,6 +84,12 @@ func NewState(base string) *State {
 		index:     make(map[string]map[string]string),
 		latest:    make(map[string]string),
 		infoCache: make(map[string]map[string]interface{}),
+		feedCache: make(map[string]cachedFeed),
+		schemes:   make(map[string]version.Comparator),
+		overrides: make(map[string]map[string]Override),
+		pins:      make(map[string]string),
+		pending:   make(map[string]map[string]Pending),
+		stale:     make(map[string]bool),
 	}
 }
 
@@ -39,8 +99,14 @@ func (s *State) UpdateIndex(launcher string, version string, infoPath string) {
 	if s.index[launcher] == nil {
 		s.index[launcher] = make(map[string]string)
 	}
+	if _, exists := s.index[launcher][version]; !exists {
+		// 记录到新版本，订阅源需要重新生成
+		s.feedGen++
+	}
 	s.index[launcher][version] = infoPath
-	s.latest[launcher] = s.pickLatest(s.index[launcher])
+	// index.json 可能已被重新写入，丢弃旧缓存
+	delete(s.infoCache, infoPath)
+	s.latest[launcher] = s.pickLatest(launcher, s.index[launcher])
 }
 
 func (s *State) RemoveVersion(launcher string, version string) {
@@ -50,81 +116,15 @@ func (s *State) RemoveVersion(launcher string, version string) {
 		return
 	}
 	delete(s.index[launcher], version)
-	s.latest[launcher] = s.pickLatest(s.index[launcher])
+	s.feedGen++
+	s.latest[launcher] = s.pickLatest(launcher, s.index[launcher])
 }
 
-// ClearLatestFlags 清除指定启动器所有版本的 is_latest 标记
-func (s *State) ClearLatestFlags(launcher string) error {
-	s.mu.RLock()
-	versions, exists := s.index[launcher]
-	s.mu.RUnlock()
-	
-	if !exists {
-		return nil // 启动器不存在，无需清除
-	}
-	
-	for _, infoPath := range versions {
-		// 检查缓存中的 is_latest 字段，如果为 true 才处理
-		s.mu.RLock()
-		info, exists := s.infoCache[infoPath]
-		s.mu.RUnlock()
-		
-		// 如果缓存存在且 is_latest 为 true，或者缓存不存在（需要读取文件），则处理
-		if !exists || (exists && info["is_latest"] == true) {
-			if err := s.clearLatestFlag(infoPath); err != nil {
-				log.Printf("清除 %s 的 latest 标记失败: %v", infoPath, err)
-				// 继续处理其他文件，不返回错误
-			}
-		}
-	}
-	
-	return nil
-}
-
-// clearLatestFlag 清除单个 index.json 文件的 is_latest 标记
-func (s *State) clearLatestFlag(infoPath string) error {
-	s.mu.RLock()
-	info, exists := s.infoCache[infoPath]
-	s.mu.RUnlock()
-	
-	// 如果缓存不存在，读取文件
-	if !exists {
-		content, err := os.ReadFile(infoPath)
-		if err != nil {
-			return fmt.Errorf("读取文件失败: %w", err)
-		}
-		
-		var fileInfo map[string]interface{}
-		if err := json.Unmarshal(content, &fileInfo); err != nil {
-			return fmt.Errorf("解析 JSON 失败: %w", err)
-		}
-		
-		info = fileInfo
-	}
-	
-	// 如果存在 is_latest 字段且为 true，则将其设置为 false
-	if isLatest, exists := info["is_latest"]; exists && isLatest == true {
-		info["is_latest"] = false
-		
-		// 重新写入文件
-		newContent, err := json.MarshalIndent(info, "", "  ")
-		if err != nil {
-			return fmt.Errorf("序列化 JSON 失败: %w", err)
-		}
-		
-		if err := os.WriteFile(infoPath, newContent, 0o644); err != nil {
-			return fmt.Errorf("写入文件失败: %w", err)
-		}
-		
-		// 更新缓存
-		s.mu.Lock()
-		s.infoCache[infoPath] = info
-		s.mu.Unlock()
-		
-		log.Printf("已清除 %s 的 latest 标记", infoPath)
-	}
-	
-	return nil
+// RefreshInfo 丢弃 index.json 的缓存，在已发布版本的 index.json 被就地改写后调用
+func (s *State) RefreshInfo(infoPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.infoCache, infoPath)
 }
 
 func (s *State) Routes(mux *http.ServeMux) {
@@ -230,6 +230,11 @@ func (s *State) Routes(mux *http.ServeMux) {
 		}
 
 		relPath := strings.TrimPrefix(path, "/download/")
+		// 暂存目录与 .partial 等隐藏文件中是未完成的版本，不对外提供
+		if downloader.IsHidden(relPath) {
+			http.NotFound(w, r)
+			return
+		}
 		fullPath := filepath.Join(s.BasePath, relPath)
 		cleanPath := filepath.Clean(fullPath)
 
@@ -247,8 +252,23 @@ func (s *State) Routes(mux *http.ServeMux) {
 		if len(parts) >= 2 {
 			launcher := parts[0]
 			version := parts[1]
+			if yanked, reason := s.Yanked(launcher, version); yanked {
+				http.Error(w, reason, http.StatusGone)
+				return
+			}
 			fileName := filepath.Base(relPath)
-			stats.RecordDownload(r, fileName, launcher, version)
+			// 客户端所在国家由其他节点提供时重定向过去，节点不可用时由本节点提供。
+			// 重定向已记录在 redirects 表中，下载由提供文件的节点统计
+			if len(parts) == 3 {
+				if target := s.federatedURL(r, launcher, version, fileName); target != "" {
+					http.Redirect(w, r, target, http.StatusFound)
+					return
+				}
+			}
+			// 种子、Metalink、index.json 与 HEAD 请求（如副本检查版本是否还在）不计入下载统计
+			if !downloader.IsSidecar(fileName) && fileName != "index.json" && r.Method != http.MethodHead {
+				stats.RecordDownload(r, fileName, launcher, version)
+			}
 		}
 
 		// 检查文件是否存在
@@ -264,6 +284,12 @@ func (s *State) Routes(mux *http.ServeMux) {
 			return
 		}
 
+		switch filepath.Ext(cleanPath) {
+		case downloader.TorrentExt:
+			w.Header().Set("Content-Type", "application/x-bittorrent")
+		case downloader.MetalinkExt:
+			w.Header().Set("Content-Type", metalink.MediaType)
+		}
 		http.ServeFile(w, r, cleanPath)
 	})
 
@@ -273,7 +299,26 @@ func (s *State) Routes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/files", s.handleFiles)
 	mux.HandleFunc("/api/latest", s.handleLatestAll)
 	mux.HandleFunc("/api/latest/", s.handleLatestLauncher)
+	mux.HandleFunc("/api/latest-history", s.handleLatestHistory)
+	mux.HandleFunc("/api/pending", s.handlePending)
+	mux.HandleFunc("/api/scans/history", s.handleScanHistory)
+	mux.HandleFunc("/api/scans/status", s.handleScanStatus)
+	mux.HandleFunc("/api/release/", s.handleRelease)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/api/mirrors", s.handleMirrors)
+	mux.HandleFunc("/api/queue", s.handleQueue)
+	mux.HandleFunc("/api/dedup", s.handleDedup)
+	mux.HandleFunc("/api/disk", s.handleDisk)
+	mux.HandleFunc("/api/federation", s.handleFederation)
+	mux.HandleFunc("/api/replica", s.handleReplica)
 	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/api/hooks/github", s.handleGitHubHook)
+
+	// 管理接口
+	s.adminRoutes(mux)
+
+	// 订阅源
+	mux.HandleFunc("/feeds/", s.handleFeed)
 }
 
 // containsDotDot 检查路径是否包含 ".." 元素
@@ -302,8 +347,8 @@ func SecurityMiddleware(next http.Handler) http.Handler {
 
 		// CORS Headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.Header().Set("Access-Control-Expose-Headers", "X-Latest-Version, X-Latest-Versions")
 
 		if r.Method == http.MethodOptions {
@@ -322,6 +367,10 @@ func (s *State) InitFromDisk() error {
 			return nil
 		}
 		if d.IsDir() {
+			// 跳过暂存目录等隐藏目录，其中是尚未发布的版本
+			if path != base && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if filepath.Base(path) != "index.json" {
@@ -338,6 +387,10 @@ func (s *State) InitFromDisk() error {
 		// 假设目录结构为 launcher/version
 		launcher := parts[0]
 		version := parts[1]
+		if err := downloader.VerifyVersionDir(filepath.Dir(path)); err != nil {
+			log.Printf("跳过不完整的版本 %s/%s: %v", launcher, version, err)
+			return nil
+		}
 		s.UpdateIndex(launcher, version, path)
 		
 		// 缓存 index.json 文件内容
@@ -354,22 +407,63 @@ func (s *State) InitFromDisk() error {
 	})
 }
 
-// pickLatest 选择最新版本
-func (s *State) pickLatest(versions map[string]string) string {
+// pickLatest 选择最新版本。调用方需持有 mu。
+func (s *State) pickLatest(launcher string, versions map[string]string) string {
+	return s.selectLatest(launcher, versions, true)
+}
+
+// pickNewest 忽略固定版本与 is_latest 标记，按排序方案选出最新的可用版本。调用方需持有 mu。
+func (s *State) pickNewest(launcher string, versions map[string]string) string {
+	return s.selectLatest(launcher, versions, false)
+}
+
+// selectLatest 选择最新版本，useFlags 为 false 时不考虑固定版本与已有的 latest 标记
+func (s *State) selectLatest(launcher string, versions map[string]string, useFlags bool) string {
 	if len(versions) == 0 {
 		return ""
 	}
-	
-	// 首先查找标记为 is_latest 的版本
+	// 管理员固定的版本优先
+	if pinned, ok := s.pins[launcher]; ok && useFlags {
+		if _, exists := versions[pinned]; exists {
+			return pinned
+		}
+	}
+	// 被撤回与待发布的版本不参与选择
+	if len(s.overrides[launcher]) > 0 || len(s.pending[launcher]) > 0 {
+		available := make(map[string]string, len(versions))
+		for v, p := range versions {
+			if o, _ := s.override(launcher, v); !o.Yanked && !s.isPending(launcher, v) {
+				available[v] = p
+			}
+		}
+		versions = available
+		if len(versions) == 0 {
+			return ""
+		}
+	}
+	cmp := s.Comparator(launcher)
+
+	// 读取每个版本的 latest 标记与发布时间
+	infos := make(map[string]version.Info, len(versions))
+	flagged := ""
 	for v, infoPath := range versions {
+		vi := version.Info{Tag: v}
 		if content, err := os.ReadFile(infoPath); err == nil {
 			var info map[string]interface{}
 			if err := json.Unmarshal(content, &info); err == nil {
-				if isLatest, ok := info["is_latest"].(bool); ok && isLatest {
-					return v
+				vi.Published = publishedAt(info)
+				// 切换中途异常退出可能留下多个标记，此时取排序最新的
+				if isLatest, ok := info["is_latest"].(bool); ok && isLatest && useFlags {
+					if flagged == "" || cmp(vi, infos[flagged]) > 0 {
+						flagged = v
+					}
 				}
 			}
 		}
+		infos[v] = vi
+	}
+	if flagged != "" {
+		return flagged
 	}
 	
 	// 如果没有找到标记为 is_latest 的版本，使用版本比较作为后备方案
@@ -389,7 +483,7 @@ func (s *State) pickLatest(versions map[string]string) string {
 	if len(stableVersions) > 0 {
 		latest := stableVersions[0]
 		for _, v := range stableVersions[1:] {
-			if compareVersions(v, latest) > 0 {
+			if cmp(infos[v], infos[latest]) > 0 {
 				latest = v
 			}
 		}
@@ -400,7 +494,7 @@ func (s *State) pickLatest(versions map[string]string) string {
 	if len(unstableVersions) > 0 {
 		latest := unstableVersions[0]
 		for _, v := range unstableVersions[1:] {
-			if compareVersions(v, latest) > 0 {
+			if cmp(infos[v], infos[latest]) > 0 {
 				latest = v
 			}
 		}
@@ -410,36 +504,62 @@ func (s *State) pickLatest(versions map[string]string) string {
 	return ""
 }
 
-// compareVersions 比较版本
-func compareVersions(v1, v2 string) int {
-	v1Clean := strings.TrimPrefix(v1, "v")
-	v2Clean := strings.TrimPrefix(v2, "v")
+// SetVersionScheme 设置启动器的版本排序方案，未设置的启动器使用自然顺序
+func (s *State) SetVersionScheme(launcher string, cmp version.Comparator) {
+	s.schemeMu.Lock()
+	defer s.schemeMu.Unlock()
+	s.schemes[launcher] = cmp
+}
 
-	parts1 := strings.Split(v1Clean, ".")
-	parts2 := strings.Split(v2Clean, ".")
+// Comparator 返回启动器的版本比较函数
+func (s *State) Comparator(launcher string) version.Comparator {
+	s.schemeMu.RLock()
+	defer s.schemeMu.RUnlock()
+	if cmp, ok := s.schemes[launcher]; ok {
+		return cmp
+	}
+	return func(a, b version.Info) int { return version.Loose(a.Tag, b.Tag) }
+}
 
-	maxLen := len(parts1)
-	if len(parts2) > maxLen {
-		maxLen = len(parts2)
+// sortVersionList 按启动器的排序方案将版本列表从新到旧排序
+func (s *State) sortVersionList(launcher string, list []map[string]any) {
+	cmp := s.Comparator(launcher)
+	key := func(info map[string]any) version.Info {
+		tag, _ := info["tag_name"].(string)
+		return version.Info{Tag: tag, Published: publishedAt(info)}
 	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return cmp(key(list[i]), key(list[j])) > 0
+	})
+}
 
-	for i := 0; i < maxLen; i++ {
-		n1 := 0
-		if i < len(parts1) {
-			fmt.Sscanf(parts1[i], "%d", &n1)
-		}
-		n2 := 0
-		if i < len(parts2) {
-			fmt.Sscanf(parts2[i], "%d", &n2)
-		}
-		if n1 > n2 {
-			return 1
+// ReleaseTimes 返回启动器已镜像版本的上游发布时间，用于估计发布节奏
+func (s *State) ReleaseTimes(launcher string) []time.Time {
+	s.mu.RLock()
+	paths := make([]string, 0, len(s.index[launcher]))
+	for _, p := range s.index[launcher] {
+		paths = append(paths, p)
+	}
+	s.mu.RUnlock()
+	var times []time.Time
+	for _, p := range paths {
+		if info, err := s.readInfo(p); err == nil {
+			if t := publishedAt(info); !t.IsZero() {
+				times = append(times, t)
+			}
 		}
-		if n1 < n2 {
-			return -1
+	}
+	return times
+}
+
+// publishedAt 解析 index.json 中的 published_at 字段
+func publishedAt(info map[string]any) time.Time {
+	if p, ok := info["published_at"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, p); err == nil {
+			return t
 		}
 	}
-	return 0
+	return time.Time{}
 }
 
 func (s *State) handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -450,9 +570,16 @@ func (s *State) handleStatus(w http.ResponseWriter, r *http.Request) {
     for launcher, versions := range s.index {
         var list []map[string]any
         for v, p := range versions {
+             o, hasOverride := s.override(launcher, v)
+             if o.Yanked || s.isPending(launcher, v) {
+                 continue
+             }
              info := map[string]any{
                  "tag_name": v,
              }
+             if hasOverride && o.Note != "" {
+                 info["admin_note"] = o.Note
+             }
              
              // 先从缓存获取 index.json 内容
              if fileInfo, ok := s.infoCache[p]; ok {
@@ -480,11 +607,7 @@ func (s *State) handleStatus(w http.ResponseWriter, r *http.Request) {
              
              list = append(list, info)
         }
-        sort.Slice(list, func(i, j int) bool {
-             v1, _ := list[i]["tag_name"].(string)
-             v2, _ := list[j]["tag_name"].(string)
-             return compareVersions(v1, v2) > 0
-        })
+        s.sortVersionList(launcher, list)
         result[launcher] = list
     }
     
@@ -498,7 +621,14 @@ func (s *State) handleLauncherStatus(w http.ResponseWriter, r *http.Request) {
 	if versions, ok := s.index[launcher]; ok {
         var list []map[string]any
         for v, p := range versions {
+             o, hasOverride := s.override(launcher, v)
+             if o.Yanked || s.isPending(launcher, v) {
+                 continue
+             }
              info := map[string]any{"tag_name": v}
+             if hasOverride && o.Note != "" {
+                 info["admin_note"] = o.Note
+             }
              
              // 先从缓存获取 index.json 内容
              if fileInfo, ok := s.infoCache[p]; ok {
@@ -526,11 +656,7 @@ func (s *State) handleLauncherStatus(w http.ResponseWriter, r *http.Request) {
              
              list = append(list, info)
         }
-        sort.Slice(list, func(i, j int) bool {
-             v1, _ := list[i]["tag_name"].(string)
-             v2, _ := list[j]["tag_name"].(string)
-             return compareVersions(v1, v2) > 0
-        })
+        s.sortVersionList(launcher, list)
 		json.NewEncoder(w).Encode(list)
 	} else {
 		http.NotFound(w, r)
@@ -564,6 +690,149 @@ func (s *State) handleLatestLauncher(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// readInfo 读取 index.json 内容，优先使用缓存。调用方不能持有 s.mu。
+func (s *State) readInfo(infoPath string) (map[string]any, error) {
+	s.mu.RLock()
+	info, ok := s.infoCache[infoPath]
+	s.mu.RUnlock()
+	if ok {
+		return info, nil
+	}
+	content, err := os.ReadFile(infoPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &info); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.infoCache[infoPath] = info
+	s.mu.Unlock()
+	return info, nil
+}
+
+// handleRelease 返回单个版本的完整元数据，并附带服务端渲染的 release 说明 HTML。
+// 路径格式：/api/release/{launcher}/{version}
+func (s *State) handleRelease(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/release/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	launcher, version := parts[0], parts[1]
+
+	s.mu.RLock()
+	infoPath, ok := s.index[launcher][version]
+	latest := s.latest[launcher]
+	o, _ := s.override(launcher, version)
+	pending := s.isPending(launcher, version)
+	s.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if o.Yanked {
+		http.Error(w, o.YankReason, http.StatusGone)
+		return
+	}
+
+	fileInfo, err := s.readInfo(infoPath)
+	if err != nil {
+		log.Printf("读取 %s 失败: %v", infoPath, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	result := map[string]any{"tag_name": version}
+	for k, val := range fileInfo {
+		if k != "is_latest" {
+			result[k] = val
+		}
+	}
+	body, _ := fileInfo["body"].(string)
+	result["body_html"] = markdown.ToHTML(body)
+	result["latest"] = version == latest
+	result["pending"] = pending
+	if o.Note != "" {
+		result["admin_note"] = o.Note
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleEvents 返回最近的运行事件与告警，支持 level、kind、launcher、limit 查询参数
+func (s *State) handleEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	list, err := events.Recent(q.Get("level"), q.Get("kind"), q.Get("launcher"), limit)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("获取事件失败: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleMirrors 返回各上游下载策略的健康状况与评分
+func (s *State) handleMirrors(w http.ResponseWriter, r *http.Request) {
+	status := []downloader.StrategyStatus{}
+	if s.Mirrors != nil {
+		status = s.Mirrors.Status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleQueue 返回下载队列中的任务，支持 limit 查询参数限制返回的已完成任务数
+func (s *State) handleQueue(w http.ResponseWriter, r *http.Request) {
+	jobs := []downloader.Job{}
+	if s.Downloader != nil {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		list, err := s.Downloader.Jobs(limit)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Printf("获取下载队列失败: %v", err)
+			return
+		}
+		jobs = list
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// handleDedup 返回按内容去重节省的磁盘空间
+func (s *State) handleDedup(w http.ResponseWriter, r *http.Request) {
+	var st blobs.Stats
+	if s.Blobs != nil {
+		var err error
+		st, err = s.Blobs.Stats()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			log.Printf("获取去重统计失败: %v", err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleDisk 返回存储目录的可用空间、占用、配额与因空间不足拒绝下载的记录
+func (s *State) handleDisk(w http.ResponseWriter, r *http.Request) {
+	if s.Quota == nil {
+		http.Error(w, "Disk Status Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	st, err := s.Quota.Status(s.launcherNames())
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("统计磁盘占用失败: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
 func (s *State) handleStats(w http.ResponseWriter, r *http.Request) {
 	data, err := stats.GetStats()
 	if err != nil {
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"lemwood_mirror/internal/events"
+	gh "lemwood_mirror/internal/github"
+)
+
+// maxHookBody 为 webhook 请求体的大小上限，release 事件的负载远小于该值
+const maxHookBody = 5 << 20
+
+// upstreamDeletedReason 为上游删除 release 时自动撤回版本的原因；
+// 上游重新发布同名 release 时只恢复以该原因撤回的版本，不影响管理员的撤回
+const upstreamDeletedReason = "上游已删除该版本"
+
+// releaseEvent 是 GitHub release 事件负载中用到的字段
+type releaseEvent struct {
+	Action  string `json:"action"`
+	Release struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		Draft   bool   `json:"draft"`
+	} `json:"release"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// hookResponse 是 webhook 的响应
+type hookResponse struct {
+	Status    string   `json:"status"` // accepted 或 ignored
+	Reason    string   `json:"reason,omitempty"`
+	Launchers []string `json:"launchers,omitempty"`
+	Yanked    []string `json:"yanked,omitempty"`   // 因上游删除 release 而撤回的启动器
+	Restored  []string `json:"restored,omitempty"` // 上游重新发布后恢复的启动器
+}
+
+// verifySignature 校验 X-Hub-Signature-256：sha256=<请求体的 HMAC-SHA256>
+func verifySignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// launchersForRepo 返回来源为该仓库的启用的 GitHub 启动器：来源地址本身就是该仓库，或最近一次解析信任的是该仓库
+func (s *State) launchersForRepo(repoURL string) []string {
+	want, err := gh.NormalizeRepoURL(repoURL)
+	if err != nil || s.Launchers == nil {
+		return nil
+	}
+	var names []string
+	for _, l := range s.Launchers.List() {
+		if l.Disabled || l.IsWebpage() {
+			continue
+		}
+		source, _ := gh.NormalizeRepoURL(l.SourceURL)
+		known := ""
+		if s.Resolver != nil {
+			if repo, err := s.Resolver.KnownRepo(l.Name); err != nil {
+				log.Printf("%s: %v", l.Name, err)
+			} else {
+				known, _ = gh.NormalizeRepoURL(repo)
+			}
+		}
+		if strings.EqualFold(source, want) || strings.EqualFold(known, want) {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+// handleGitHubHook 处理 POST /api/hooks/github：校验签名后，对 release 的发布、编辑与删除事件
+// 立即扫描对应的启动器；上游删除的已镜像版本按撤回处理。
+func (s *State) handleGitHubHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.WebhookSecret == "" {
+		http.Error(w, "Webhook Disabled", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBody))
+	if err != nil {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !verifySignature(s.WebhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		log.Printf("安全警告：来自 %s 的 GitHub webhook 签名校验失败", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	event := r.Header.Get("X-GitHub-Event")
+	if event != "release" {
+		// ping 等其他事件只确认收到
+		json.NewEncoder(w).Encode(hookResponse{Status: "ignored", Reason: "不处理 " + event + " 事件"})
+		return
+	}
+	var ev releaseEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+		return
+	}
+	switch ev.Action {
+	case "published", "edited", "deleted":
+	default:
+		json.NewEncoder(w).Encode(hookResponse{Status: "ignored", Reason: "不处理 " + ev.Action + " 操作"})
+		return
+	}
+	if ev.Release.Draft {
+		json.NewEncoder(w).Encode(hookResponse{Status: "ignored", Reason: "草稿 release"})
+		return
+	}
+	names := s.launchersForRepo(ev.Repository.HTMLURL)
+	if len(names) == 0 {
+		log.Printf("GitHub webhook: 仓库 %s 没有对应的启动器", ev.Repository.FullName)
+		json.NewEncoder(w).Encode(hookResponse{Status: "ignored", Reason: "没有对应的启动器"})
+		return
+	}
+
+	resp := hookResponse{Status: "accepted", Launchers: names}
+	version := ev.Release.TagName
+	if version == "" {
+		version = ev.Release.Name
+	}
+	for _, name := range names {
+		log.Printf("GitHub webhook: %s 的 release %s 已%s，立即扫描 %s", ev.Repository.FullName, version, ev.Action, name)
+		yanked, reason := s.Yanked(name, version)
+		switch {
+		case ev.Action == "deleted" && version != "" && !yanked && s.hasVersion(name, version):
+			if err := s.Yank(name, version, upstreamDeletedReason); err != nil {
+				log.Printf("%s: 撤回上游已删除的版本 %s 失败: %v", name, version, err)
+				break
+			}
+			events.Alert(events.KindUpstreamDeleted, name, "上游删除了 release %s，已撤回该版本", version)
+			resp.Yanked = append(resp.Yanked, name)
+		case ev.Action == "published" && yanked && reason == upstreamDeletedReason:
+			if err := s.Unyank(name, version); err != nil {
+				log.Printf("%s: 恢复上游重新发布的版本 %s 失败: %v", name, version, err)
+				break
+			}
+			log.Printf("%s: 上游重新发布了 release %s，已恢复该版本", name, version)
+			resp.Restored = append(resp.Restored, name)
+		}
+		if s.ScanLauncher != nil {
+			go s.ScanLauncher(name, ScanOptions{})
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
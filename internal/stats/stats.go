@@ -14,12 +14,13 @@ import (
 
 // IPInfo 结构体
 type IPInfo struct {
-	Status  string `json:"status"`
-	Country string `json:"country"`
-	Region  string `json:"regionName"`
-	City    string `json:"city"`
-	Query   string `json:"query"`
-	Expires time.Time // 缓存过期时间
+	Status      string    `json:"status"`
+	Country     string    `json:"country"`
+	CountryCode string    `json:"countryCode"`
+	Region      string    `json:"regionName"`
+	City        string    `json:"city"`
+	Query       string    `json:"query"`
+	Expires     time.Time // 缓存过期时间
 }
 
 // IP 缓存，避免重复请求
@@ -82,6 +83,26 @@ func RecordDownload(r *http.Request, fileName, launcher, version string) {
 	}()
 }
 
+// ClientCountry 返回请求来源的国家代码与名称，最多等待 wait。
+// 超时时返回空字符串，查询在后台继续并写入缓存，之后的请求可以直接使用。局域网地址返回 "Local"。
+func ClientCountry(r *http.Request, wait time.Duration) (code, name string) {
+	ip := getClientIP(r)
+	ch := make(chan *IPInfo, 1)
+	go func() { ch <- getIPInfo(ip) }()
+	select {
+	case info := <-ch:
+		if info == nil {
+			return "", ""
+		}
+		if info.Country == "Local" {
+			return "Local", "Local"
+		}
+		return info.CountryCode, info.Country
+	case <-time.After(wait):
+		return "", ""
+	}
+}
+
 func getClientIP(r *http.Request) string {
 	ip := r.Header.Get("X-Forwarded-For")
 	if ip == "" {
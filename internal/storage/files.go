@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type FileNode struct {
@@ -39,6 +40,10 @@ func buildNode(path string, fi os.FileInfo) (FileNode, error) {
 		return n, err
 	}
 	for _, e := range entries {
+		// 隐藏的暂存目录中是尚未发布的版本
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
 		info, err := e.Info()
 		if err != nil {
 			return n, err
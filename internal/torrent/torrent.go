@@ -0,0 +1,209 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+)
+
+const (
+	// BlockSize 是 BEP 52 merkle 树叶子对应的块大小
+	BlockSize = 16 << 10
+
+	minPieceLength = 64 << 10
+	maxPieceLength = 16 << 20
+	targetPieces   = 1500
+)
+
+// Options 是生成 .torrent 的选项
+type Options struct {
+	Name     string   // 种子中的文件名，为空时使用路径的文件名
+	WebSeeds []string // BEP 19 web seed，直接指向文件的 URL
+	Trackers []string // tracker 地址，为空时依赖 DHT 与 web seed
+}
+
+// File 是生成的 v1 + v2 混合种子
+type File struct {
+	Data        []byte // bencode 编码的 .torrent 内容
+	InfoHashV1  string // info 字典的 SHA-1（十六进制）
+	InfoHashV2  string // info 字典的 SHA-256（十六进制）
+	PieceLength int64
+}
+
+// Create 读取 path 生成单文件的 v1 + v2 混合种子（BEP 3 / BEP 52）
+func Create(path string, opts Options) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	name := opts.Name
+	if name == "" {
+		name = fi.Name()
+	}
+	size := fi.Size()
+	pieceLength := choosePieceLength(size)
+
+	// 一次读取同时计算 v1 的分片 SHA-1 与 v2 每个 16 KiB 块的 SHA-256
+	var pieces []byte
+	var leaves [][32]byte
+	piece := sha1.New()
+	var inPiece int64
+	block := make([]byte, BlockSize)
+	for {
+		n, err := io.ReadFull(f, block)
+		if n > 0 {
+			leaves = append(leaves, sha256.Sum256(block[:n]))
+			piece.Write(block[:n])
+			inPiece += int64(n)
+			if inPiece == pieceLength {
+				pieces = piece.Sum(pieces)
+				piece.Reset()
+				inPiece = 0
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if inPiece > 0 {
+		pieces = piece.Sum(pieces)
+	}
+	if int64(len(leaves))*BlockSize < size {
+		return nil, errors.New("读取的长度与文件大小不一致")
+	}
+
+	entry := map[string]any{"length": size}
+	var layers map[string]any
+	if size > 0 {
+		piecesRoot, layer := merkle(leaves, int(pieceLength/BlockSize))
+		entry["pieces root"] = piecesRoot[:]
+		if size > pieceLength {
+			layers = map[string]any{string(piecesRoot[:]): layer}
+		}
+	}
+	info := map[string]any{
+		"name":         name,
+		"piece length": pieceLength,
+		"length":       size,
+		"pieces":       pieces,
+		"meta version": 2,
+		"file tree":    map[string]any{name: map[string]any{"": entry}},
+	}
+	var infoBuf bytes.Buffer
+	if err := encode(&infoBuf, info); err != nil {
+		return nil, err
+	}
+
+	meta := map[string]any{
+		"info":       info,
+		"created by": "lemwood_mirror",
+	}
+	if layers != nil {
+		meta["piece layers"] = layers
+	}
+	if len(opts.WebSeeds) > 0 {
+		meta["url-list"] = opts.WebSeeds
+	}
+	if len(opts.Trackers) > 0 {
+		meta["announce"] = opts.Trackers[0]
+		tiers := make([]any, 0, len(opts.Trackers))
+		for _, t := range opts.Trackers {
+			tiers = append(tiers, []string{t})
+		}
+		meta["announce-list"] = tiers
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, meta); err != nil {
+		return nil, err
+	}
+	v1 := sha1.Sum(infoBuf.Bytes())
+	v2 := sha256.Sum256(infoBuf.Bytes())
+	return &File{
+		Data:        buf.Bytes(),
+		InfoHashV1:  hex.EncodeToString(v1[:]),
+		InfoHashV2:  hex.EncodeToString(v2[:]),
+		PieceLength: pieceLength,
+	}, nil
+}
+
+// Magnet 返回同时包含 v1 与 v2 info hash 的磁力链接
+func Magnet(name, infoHashV1, infoHashV2 string, webSeeds, trackers []string) string {
+	q := "magnet:?xt=urn:btih:" + infoHashV1 + "&xt=urn:btmh:1220" + infoHashV2 + "&dn=" + url.QueryEscape(name)
+	for _, ws := range webSeeds {
+		q += "&ws=" + url.QueryEscape(ws)
+	}
+	for _, tr := range trackers {
+		q += "&tr=" + url.QueryEscape(tr)
+	}
+	return q
+}
+
+// choosePieceLength 选择使分片数量接近 targetPieces 的 2 的幂
+func choosePieceLength(size int64) int64 {
+	pl := int64(minPieceLength)
+	for pl < maxPieceLength && size/pl > targetPieces {
+		pl *= 2
+	}
+	return pl
+}
+
+// merkle 计算文件的 pieces root 与分片层（BEP 52）。
+// 叶子数补齐到 2 的幂，补齐的叶子为全零；文件不超过一个分片时树的宽度由块数决定。
+func merkle(leaves [][32]byte, blocksPerPiece int) ([32]byte, []byte) {
+	var zero [32]byte
+	if len(leaves) <= blocksPerPiece {
+		return root(leaves, nextPow2(len(leaves)), zero), nil
+	}
+	var layer []byte
+	var nodes [][32]byte
+	for i := 0; i < len(leaves); i += blocksPerPiece {
+		end := min(i+blocksPerPiece, len(leaves))
+		h := root(leaves[i:end], blocksPerPiece, zero)
+		nodes = append(nodes, h)
+		layer = append(layer, h[:]...)
+	}
+	// 分片层补齐的节点是全零子树的根，而不是全零
+	pad := root(nil, blocksPerPiece, zero)
+	return root(nodes, nextPow2(len(nodes)), pad), layer
+}
+
+// root 将 nodes 用 pad 补齐到 width 个后逐层两两哈希，返回根
+func root(nodes [][32]byte, width int, pad [32]byte) [32]byte {
+	level := make([][32]byte, width)
+	copy(level, nodes)
+	for i := len(nodes); i < width; i++ {
+		level[i] = pad
+	}
+	for len(level) > 1 {
+		next := level[:len(level)/2]
+		for i := range next {
+			var pair [64]byte
+			copy(pair[:32], level[2*i][:])
+			copy(pair[32:], level[2*i+1][:])
+			next[i] = sha256.Sum256(pair[:])
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func nextPow2(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,381 @@
+// Package version 提供可按启动器选择的版本号排序方案。
+package version
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 版本排序方案
+const (
+	SchemeLoose    = "loose"  // 自然顺序（默认）：数字按数值比较，带 -beta 等后缀的预发布版本更小
+	SchemeSemVer   = "semver" // SemVer 2.0
+	SchemeBuild    = "build"  // 纯构建号，例如 141000
+	SchemeCalendar = "calver" // 日历版本，例如 2024.10.03、24.10.1
+	SchemePEP440   = "pep440" // 类似 Python PEP 440：1.0.dev1 < 1.0a1 < 1.0rc1 < 1.0 < 1.0.post1
+	SchemeDate     = "date"   // 按上游发布时间排序
+	SchemeRegex    = "regex"  // 自定义正则，按捕获组顺序比较
+)
+
+// Info 是参与比较的版本信息
+type Info struct {
+	Tag       string
+	Published time.Time
+}
+
+// Comparator 比较两个版本，a 更新时返回正数，更旧时返回负数，相同返回 0
+type Comparator func(a, b Info) int
+
+// Spec 描述启动器使用的排序方案
+type Spec struct {
+	Scheme string
+	// Pattern 为 regex 方案的正则表达式
+	Pattern string
+	// Order 为 regex 方案中参与比较的捕获组序号（从 1 开始），按优先级排列；为空时按 1..n 顺序
+	Order []int
+}
+
+// New 根据方案创建比较函数。无法解析的版本号总是排在能解析的版本号之后，再按自然顺序比较。
+func New(spec Spec) (Comparator, error) {
+	switch strings.ToLower(spec.Scheme) {
+	case "", SchemeLoose:
+		return func(a, b Info) int { return Loose(a.Tag, b.Tag) }, nil
+	case SchemeSemVer:
+		return func(a, b Info) int { return SemVer(a.Tag, b.Tag) }, nil
+	case SchemeBuild:
+		return func(a, b Info) int { return Build(a.Tag, b.Tag) }, nil
+	case SchemeCalendar:
+		return func(a, b Info) int { return Calendar(a.Tag, b.Tag) }, nil
+	case SchemePEP440:
+		return func(a, b Info) int { return PEP440(a.Tag, b.Tag) }, nil
+	case SchemeDate:
+		return func(a, b Info) int {
+			switch {
+			case a.Published.After(b.Published):
+				return 1
+			case a.Published.Before(b.Published):
+				return -1
+			}
+			return Loose(a.Tag, b.Tag)
+		}, nil
+	case SchemeRegex:
+		if spec.Pattern == "" {
+			return nil, fmt.Errorf("regex 排序方案需要 version_regex")
+		}
+		re, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("version_regex 无效: %w", err)
+		}
+		order := spec.Order
+		if len(order) == 0 {
+			for i := 1; i <= re.NumSubexp(); i++ {
+				order = append(order, i)
+			}
+		}
+		for _, g := range order {
+			if g < 0 || g > re.NumSubexp() {
+				return nil, fmt.Errorf("version_regex_order 中的捕获组 %d 不存在", g)
+			}
+		}
+		return func(a, b Info) int { return regexCompare(re, order, a.Tag, b.Tag) }, nil
+	}
+	return nil, fmt.Errorf("未知的版本排序方案 %q", spec.Scheme)
+}
+
+// Loose 按自然顺序比较：连续的数字按数值比较，其余字符按字典序比较；
+// 一方是另一方的前缀时，以 "-" 开头的剩余部分视为预发布版本，更小。开头的 v 与非数字前缀会被忽略。
+func Loose(a, b string) int {
+	a, b = trimPrefix(a), trimPrefix(b)
+	for a != "" && b != "" {
+		ca, cb := a[0], b[0]
+		if isDigit(ca) && isDigit(cb) {
+			na, ra := leadingDigits(a)
+			nb, rb := leadingDigits(b)
+			if c := na.Cmp(nb); c != 0 {
+				return c
+			}
+			a, b = ra, rb
+			continue
+		}
+		if ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		if b[0] == '-' {
+			return 1
+		}
+		return -1
+	default:
+		if a[0] == '-' {
+			return -1
+		}
+		return 1
+	}
+}
+
+// trimPrefix 去掉版本号前面的非数字前缀，例如 "v1.2"、"release-1.3"
+func trimPrefix(s string) string {
+	for i := 0; i < len(s); i++ {
+		if isDigit(s[i]) {
+			return s[i:]
+		}
+	}
+	return s
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// leadingDigits 返回开头连续数字的数值（任意长度）与剩余部分
+func leadingDigits(s string) (*big.Int, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	n, _ := new(big.Int).SetString(s[:i], 10)
+	if n == nil {
+		n = new(big.Int)
+	}
+	return n, s[i:]
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
+
+// 无法解析的版本号排在能解析的版本号之后
+func cmpParsed(okA, okB bool, a, b string) (int, bool) {
+	switch {
+	case okA && okB:
+		return 0, false
+	case okA:
+		return 1, true
+	case okB:
+		return -1, true
+	}
+	return Loose(a, b), true
+}
+
+var semverRe = regexp.MustCompile(`^[vV]?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// SemVer 按 SemVer 2.0 比较：主、次、修订号按数值比较；带预发布标识的版本更小，
+// 预发布标识逐段比较（数字段按数值且小于字母段，段数少者更小）；构建元数据不参与比较。
+func SemVer(a, b string) int {
+	ma, mb := semverRe.FindStringSubmatch(a), semverRe.FindStringSubmatch(b)
+	if c, done := cmpParsed(ma != nil, mb != nil, a, b); done {
+		return c
+	}
+	for i := 1; i <= 3; i++ {
+		na, _ := new(big.Int).SetString(ma[i], 10)
+		nb, _ := new(big.Int).SetString(mb[i], 10)
+		if c := na.Cmp(nb); c != 0 {
+			return c
+		}
+	}
+	pa, pb := ma[4], mb[4]
+	switch {
+	case pa == "" && pb == "":
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	}
+	ia, ib := strings.Split(pa, "."), strings.Split(pb, ".")
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		if c := comparePrereleaseIdent(ia[i], ib[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(int64(len(ia)), int64(len(ib)))
+}
+
+func comparePrereleaseIdent(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		switch {
+		case na > nb:
+			return 1
+		case na < nb:
+			return -1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+var digitsRe = regexp.MustCompile(`\d+`)
+
+// Build 按版本号中的第一个数字序列（构建号）比较，例如 "141000"、"build-2031"
+func Build(a, b string) int {
+	da, db := digitsRe.FindString(a), digitsRe.FindString(b)
+	if c, done := cmpParsed(da != "", db != "", a, b); done {
+		return c
+	}
+	na, _ := new(big.Int).SetString(da, 10)
+	nb, _ := new(big.Int).SetString(db, 10)
+	if c := na.Cmp(nb); c != 0 {
+		return c
+	}
+	return Loose(a, b)
+}
+
+var calverRe = regexp.MustCompile(`(\d{2,4})[.\-_]?(\d{1,2})(?:[.\-_]?(\d{1,2}))?(?:[.\-_](\d+))?`)
+
+// Calendar 按日历版本比较：依次比较年、月、日与同日序号，两位数的年份视为 20xx。
+// 支持 2024.10.03、2024-10-03、20241003、24.10.1、2024.10.03.2 等写法。
+func Calendar(a, b string) int {
+	ka, okA := calendarKey(a)
+	kb, okB := calendarKey(b)
+	if c, done := cmpParsed(okA, okB, a, b); done {
+		return c
+	}
+	for i := range ka {
+		if c := cmpInt(ka[i], kb[i]); c != 0 {
+			return c
+		}
+	}
+	return Loose(a, b)
+}
+
+func calendarKey(s string) ([4]int64, bool) {
+	var k [4]int64
+	m := calverRe.FindStringSubmatch(s)
+	if m == nil {
+		return k, false
+	}
+	for i := 1; i <= 4; i++ {
+		if m[i] != "" {
+			k[i-1], _ = strconv.ParseInt(m[i], 10, 64)
+		}
+	}
+	if len(m[1]) == 2 {
+		k[0] += 2000
+	}
+	if len(m[1]) == 3 || k[1] < 1 || k[1] > 12 || k[2] > 31 {
+		return k, false
+	}
+	return k, true
+}
+
+var pep440Re = regexp.MustCompile(`(?i)^\s*v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+	`(?:[-_.]?(a|alpha|b|beta|c|rc|pre|preview)[-_.]?(\d*))?` +
+	`(?:(?:-(\d+))|(?:[-_.]?(post|rev|r)[-_.]?(\d*)))?` +
+	`(?:[-_.]?(dev)[-_.]?(\d*))?` +
+	`(?:\+[a-z0-9]+(?:[-_.][a-z0-9]+)*)?\s*$`)
+
+type pep440Key struct {
+	epoch   int64
+	release []int64
+	pre     int64 // -2: 只有 dev 的开发版；0/1/2: a/b/rc；3: 正式版
+	preN    int64
+	post    int64 // -1 表示没有 post
+	dev     int64 // math.MaxInt64 表示没有 dev
+}
+
+// PEP440 按类似 Python PEP 440 的规则比较：
+// 1.0.dev1 < 1.0a1 < 1.0b2 < 1.0rc1 < 1.0 < 1.0.post1，release 段末尾的 0 不影响比较。
+func PEP440(a, b string) int {
+	ka, okA := parsePEP440(a)
+	kb, okB := parsePEP440(b)
+	if c, done := cmpParsed(okA, okB, a, b); done {
+		return c
+	}
+	if c := cmpInt(ka.epoch, kb.epoch); c != 0 {
+		return c
+	}
+	for i := 0; i < len(ka.release) || i < len(kb.release); i++ {
+		var x, y int64
+		if i < len(ka.release) {
+			x = ka.release[i]
+		}
+		if i < len(kb.release) {
+			y = kb.release[i]
+		}
+		if c := cmpInt(x, y); c != 0 {
+			return c
+		}
+	}
+	for _, c := range []int{
+		cmpInt(ka.pre, kb.pre), cmpInt(ka.preN, kb.preN),
+		cmpInt(ka.post, kb.post), cmpInt(ka.dev, kb.dev),
+	} {
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func parsePEP440(s string) (pep440Key, bool) {
+	m := pep440Re.FindStringSubmatch(s)
+	if m == nil {
+		return pep440Key{}, false
+	}
+	num := func(v string) int64 {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	k := pep440Key{epoch: num(m[1]), pre: 3, post: -1, dev: 1<<63 - 1}
+	for _, p := range strings.Split(m[2], ".") {
+		k.release = append(k.release, num(p))
+	}
+	switch strings.ToLower(m[3]) {
+	case "a", "alpha":
+		k.pre = 0
+	case "b", "beta":
+		k.pre = 1
+	case "c", "rc", "pre", "preview":
+		k.pre = 2
+	}
+	k.preN = num(m[4])
+	if m[5] != "" {
+		k.post = num(m[5])
+	} else if m[6] != "" {
+		k.post = num(m[7])
+	}
+	if m[8] != "" {
+		k.dev = num(m[9])
+		if m[3] == "" && k.post < 0 {
+			k.pre = -2
+		}
+	}
+	return k, true
+}
+
+// regexCompare 按 order 指定的捕获组依次比较，两边都是数字时按数值比较，否则按自然顺序比较
+func regexCompare(re *regexp.Regexp, order []int, a, b string) int {
+	ma, mb := re.FindStringSubmatch(a), re.FindStringSubmatch(b)
+	if c, done := cmpParsed(ma != nil, mb != nil, a, b); done {
+		return c
+	}
+	for _, g := range order {
+		if c := Loose(ma[g], mb[g]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}